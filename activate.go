@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// ActivateOptions holds the options of the activate process
+type ActivateOptions struct {
+	// URLMatch limits activation to hooks with a matching config url. Nil matches all hooks.
+	URLMatch *regexp.Regexp
+	DryRun   bool
+	Yes      bool
+}
+
+// setWebHookActive enables or disables the delivery of a webhook
+// @arg ctx context.Context - Cancels requests when done
+// @arg hook WebHook
+// @arg active bool
+// @return error
+func setWebHookActive(ctx context.Context, hook WebHook, active bool) error {
+	return changeWebHooksOf(ctx, hook.Owner(), hook.URL, "PATCH", map[string]bool{"active": active})
+}
+
+// Executes the activation of inactive webhooks passing the filter
+// @arg ctx context.Context - Cancels requests when done
+// @arg options ActivateOptions
+// @arg filter HookFilter
+// @return error
+func executeActivate(ctx context.Context, options ActivateOptions, filter HookFilter) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("           A C T I V A T E")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	fmt.Println(au.Bold(au.Gray("Checking GitHub repo(s) for inactive webhooks...\n")))
+
+	var hooksToActivate []WebHook
+	var totalActivateOutput string
+
+	failedRepos := 0
+
+	// For each repo...
+	for index, repo := range reposContainer.Repos {
+		if deadlineReached(ctx, index, len(reposContainer.Repos)) {
+			fmt.Println(au.Red("Activation skipped as the deadline was reached."))
+			return ctx.Err()
+		}
+		printProgress(index, len(reposContainer.Repos), repo.Name)
+
+		// Get web hooks
+		webHooks, err := getWebHooks(ctx, repo)
+		if err != nil {
+			clearProgress()
+			fmt.Print(repoErrorMessage(err))
+			if isRepoFailure(err) {
+				failedRepos++
+			}
+			continue
+		}
+		webHooks.Hooks = webhookit.FilterWebHooks(webHooks.Hooks, filter)
+
+		for _, hook := range webHooks.Hooks {
+			if hook.Active || (options.URLMatch != nil && !options.URLMatch.MatchString(hook.Config.URL)) {
+				continue
+			}
+			totalActivateOutput += fmt.Sprintf("%s => %s\n", au.Bold(au.Magenta(repo.Name)), statusToString(hook))
+			hooksToActivate = append(hooksToActivate, hook)
+		}
+	}
+
+	clearProgress()
+
+	if len(hooksToActivate) == 0 {
+		fmt.Println(au.Green("Found no inactive hooks to activate."))
+		return scanError(ctx, failedRepos, len(reposContainer.Repos))
+	}
+	fmt.Printf("%s %d %s\n\n%s\n", au.Bold(au.Gray("Found")), au.Bold(au.Brown(len(hooksToActivate))), au.Bold(au.Gray("inactive hooks to activate:")), totalActivateOutput)
+
+	if options.DryRun {
+		fmt.Println(au.Bold(au.Green("DRY RUN - no webhooks were activated")))
+		return scanError(ctx, failedRepos, len(reposContainer.Repos))
+	}
+
+	question := fmt.Sprintf("%s\n", au.Bold("Do you wish to activate the selected web hooks? They will receive deliveries again."))
+	if !confirmPassPhrase(question, "activation", options.Yes) {
+		fmt.Println(au.Green("\nActivation aborted."))
+		return scanError(ctx, failedRepos, len(reposContainer.Repos))
+	}
+
+	failed := 0
+	for _, hook := range hooksToActivate {
+		if err := setWebHookActive(ctx, hook, true); err != nil {
+			fmt.Printf("- %s %s : %s\n", au.Red("Error activating web hook"), hook.URL, au.Red(err))
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to activate %d of %d web hooks", failed, len(hooksToActivate))
+	}
+	fmt.Println(au.Green("\nActivation completed."))
+	return scanError(ctx, failedRepos, len(reposContainer.Repos))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// APIStats counts the API requests made since webhookit started, e.g. to predict
+// the rate limit a scan of a larger org will consume
+type APIStats struct {
+	mutex sync.Mutex
+	// Requests is the number of requests made, including retries
+	Requests int
+	// RateLimitRemaining is the remaining rate limit reported by the latest response.
+	// -1 until a response reports it.
+	RateLimitRemaining int
+	Start              time.Time
+}
+
+// apiStats counts every request made by apiClient
+var apiStats = &APIStats{RateLimitRemaining: -1, Start: time.Now()}
+
+// record counts a request
+// @arg response *http.Response - Response to the request, or nil if it failed to be made
+func (s *APIStats) record(response *http.Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Requests++
+	if response == nil {
+		return
+	}
+	if remaining, err := strconv.Atoi(response.Header.Get("X-RateLimit-Remaining")); err == nil {
+		s.RateLimitRemaining = remaining
+	}
+}
+
+// ToString returns the number of requests made and the time taken e.g.
+// Made 412 API requests in 38s, 4588 rate-limit remaining
+func (s *APIStats) ToString() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	output := fmt.Sprintf("Made %d API requests in %s", s.Requests, time.Since(s.Start).Round(time.Second))
+	if s.RateLimitRemaining >= 0 {
+		output += fmt.Sprintf(", %d rate-limit remaining", s.RateLimitRemaining)
+	}
+	return output
+}
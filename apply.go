@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// ApplyOptions holds the options of the apply process
+type ApplyOptions struct {
+	// SpecPath is the path of the spec file of desired webhooks
+	SpecPath string
+	// Prune destroys webhooks whose config URL is not in the spec of their repo
+	Prune  bool
+	DryRun bool
+	Yes    bool
+}
+
+// Spec is the desired set of webhooks of each repo
+type Spec struct {
+	Repos []struct {
+		Name  string     `json:"name"`
+		Hooks []HookSpec `json:"hooks"`
+	} `json:"repos"`
+}
+
+// HookSpec is the desired state of a single webhook, identified by its config URL
+type HookSpec struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	ContentType string   `json:"content_type"`
+	// Active defaults to true when not given
+	Active *bool `json:"active"`
+}
+
+// isActive returns whether the webhook should be active
+// @return bool
+func (s HookSpec) isActive() bool {
+	return s.Active == nil || *s.Active
+}
+
+// events returns the events the webhook should be subscribed to, which default to push like GitHub
+// @return []string
+func (s HookSpec) events() []string {
+	if len(s.Events) == 0 {
+		return []string{"push"}
+	}
+	return s.Events
+}
+
+// drifted returns whether a webhook differs from the spec. A spec without a
+// content type accepts any content type.
+// @arg hook WebHook
+// @return bool
+func (s HookSpec) drifted(hook WebHook) bool {
+	if hook.Active != s.isActive() || !sameStrings(hook.Events, s.events()) {
+		return true
+	}
+	return s.ContentType != "" && webhookit.NormalizeContentType(hook.Config.ContentType) != webhookit.NormalizeContentType(s.ContentType)
+}
+
+// sameStrings returns whether two arrays hold the same strings in any order
+// @arg a []string
+// @arg b []string
+// @return bool
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readSpec reads and validates a spec file
+// @arg filePath string
+// @return Spec
+// @return error
+func readSpec(filePath string) (Spec, error) {
+	var spec Spec
+
+	jsonBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return Spec{}, err
+	}
+	if err := json.Unmarshal(jsonBytes, &spec); err != nil {
+		return Spec{}, err
+	}
+
+	seenRepos := make(map[string]bool)
+	for _, repo := range spec.Repos {
+		if repo.Name == "" {
+			return Spec{}, fmt.Errorf("repo with no name")
+		}
+		if seenRepos[repo.Name] {
+			return Spec{}, fmt.Errorf("repo %s is listed more than once", repo.Name)
+		}
+		seenRepos[repo.Name] = true
+
+		seenURLs := make(map[string]bool)
+		for _, hook := range repo.Hooks {
+			if hook.URL == "" {
+				return Spec{}, fmt.Errorf("hook of %s with no url", repo.Name)
+			}
+			// Webhooks are matched by normalized config URL so variants of one URL are the same webhook
+			configURL := webhookit.NormalizeConfigURL(hook.URL)
+			if seenURLs[configURL] {
+				return Spec{}, fmt.Errorf("url %s is listed more than once for %s", hook.URL, repo.Name)
+			}
+			seenURLs[configURL] = true
+		}
+	}
+	return spec, nil
+}
+
+// applyChange is a single write needed to converge a repo to its spec
+type applyChange struct {
+	Repo string
+	// Action is one of create, update or delete
+	Action string
+	// Hook is the existing webhook to update or delete
+	Hook WebHook
+	// Spec is the desired webhook to create or update to
+	Spec HookSpec
+}
+
+// ToString returns a formatted string of the change
+func (c applyChange) ToString() string {
+	switch c.Action {
+	case "create":
+		return fmt.Sprintf("%s => %s %s", au.Bold(au.Magenta(c.Repo)), au.Green("create"), c.Spec.URL)
+	case "update":
+		return fmt.Sprintf("%s => %s %s", au.Bold(au.Magenta(c.Repo)), au.Brown("update"), c.Spec.URL)
+	default:
+		return fmt.Sprintf("%s => %s %s", au.Bold(au.Magenta(c.Repo)), au.Red("delete"), c.Hook.Config.URL)
+	}
+}
+
+// planRepo works out the changes needed to converge the webhooks of a repo to its spec
+// @arg repoName string
+// @arg existing []WebHook
+// @arg hookSpecs []HookSpec
+// @arg prune bool - Whether webhooks not in the spec are deleted
+// @return []applyChange
+func planRepo(repoName string, existing []WebHook, hookSpecs []HookSpec, prune bool) []applyChange {
+	var changes []applyChange
+
+	// The first webhook of each normalized config URL is the one converged to the spec
+	existingByURL := make(map[string]WebHook)
+	for _, hook := range existing {
+		configURL := webhookit.NormalizeConfigURL(hook.Config.URL)
+		if _, ok := existingByURL[configURL]; !ok {
+			existingByURL[configURL] = hook
+		}
+	}
+
+	wanted := make(map[string]bool, len(hookSpecs))
+	for _, hookSpec := range hookSpecs {
+		configURL := webhookit.NormalizeConfigURL(hookSpec.URL)
+		wanted[configURL] = true
+		hook, ok := existingByURL[configURL]
+		switch {
+		case !ok:
+			changes = append(changes, applyChange{Repo: repoName, Action: "create", Spec: hookSpec})
+		case hookSpec.drifted(hook):
+			changes = append(changes, applyChange{Repo: repoName, Action: "update", Hook: hook, Spec: hookSpec})
+		}
+	}
+
+	if prune {
+		for _, hook := range existing {
+			// Duplicates of a wanted webhook are pruned too
+			configURL := webhookit.NormalizeConfigURL(hook.Config.URL)
+			if wanted[configURL] && existingByURL[configURL].ID == hook.ID {
+				continue
+			}
+			changes = append(changes, applyChange{Repo: repoName, Action: "delete", Hook: hook})
+		}
+	}
+	return changes
+}
+
+// updateWebHookSpec updates the events, active state and content type of a webhook
+// to match a spec, keeping the rest of its config such as its secret
+// @arg ctx context.Context - Cancels requests when done
+// @arg hook WebHook
+// @arg hookSpec HookSpec
+// @return error
+func updateWebHookSpec(ctx context.Context, hook WebHook, hookSpec HookSpec) error {
+	update := map[string]interface{}{
+		"active": hookSpec.isActive(),
+		"events": hookSpec.events(),
+	}
+	if err := changeWebHooksOf(ctx, hook.Owner(), hook.URL, "PATCH", update); err != nil {
+		return err
+	}
+	if hookSpec.ContentType == "" || webhookit.NormalizeContentType(hook.Config.ContentType) == webhookit.NormalizeContentType(hookSpec.ContentType) {
+		return nil
+	}
+	config := map[string]string{
+		"url":          hook.Config.URL,
+		"content_type": webhookit.NormalizeContentType(hookSpec.ContentType),
+	}
+	return changeWebHooksOf(ctx, hook.Owner(), hook.URL+"/config", "PATCH", config)
+}
+
+// applyChangeToRepo makes the API request of a change
+// @arg ctx context.Context - Cancels requests when done
+// @arg change applyChange
+// @return error
+func applyChangeToRepo(ctx context.Context, change applyChange) error {
+	switch change.Action {
+	case "create":
+		hook := WebHook{Active: change.Spec.isActive(), Events: change.Spec.events()}
+		hook.Config.URL = change.Spec.URL
+		hook.Config.ContentType = webhookit.NormalizeContentType(change.Spec.ContentType)
+		return createWebHook(ctx, Repo{Name: change.Repo}, hook)
+	case "update":
+		return updateWebHookSpec(ctx, change.Hook, change.Spec)
+	default:
+		return apiClient.DeleteWebHook(ctx, change.Hook)
+	}
+}
+
+// Executes the convergence of repos to the webhooks of a spec file. Re-running
+// against converged repos makes no write requests.
+// @arg ctx context.Context - Cancels requests when done
+// @arg options ApplyOptions
+// @return error
+func executeApply(ctx context.Context, options ApplyOptions) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("              A P P L Y")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	spec, err := readSpec(options.SpecPath)
+	if err != nil {
+		printError("Issue reading spec file:", err)
+	}
+
+	fmt.Println(au.Bold(au.Gray(fmt.Sprintf("Comparing %d repo(s) with %s...\n", len(spec.Repos), options.SpecPath))))
+
+	var changes []applyChange
+	deletes, failedRepos := 0, 0
+
+	// Plan every change before making any
+	for index, repo := range spec.Repos {
+		if deadlineReached(ctx, index, len(spec.Repos)) {
+			fmt.Println(au.Red("Apply skipped as the deadline was reached."))
+			return ctx.Err()
+		}
+		printProgress(index, len(spec.Repos), repo.Name)
+
+		webHooks, err := getWebHooks(ctx, Repo{Name: repo.Name})
+		if err != nil {
+			clearProgress()
+			fmt.Print(repoErrorMessage(err))
+			if isRepoFailure(err) {
+				failedRepos++
+			}
+			continue
+		}
+		for _, change := range planRepo(repo.Name, webHooks.Hooks, repo.Hooks, options.Prune) {
+			if change.Action == "delete" {
+				deletes++
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	clearProgress()
+
+	if len(changes) == 0 {
+		fmt.Println(au.Green("All repos match the spec. No changes needed."))
+		return scanError(ctx, failedRepos, len(spec.Repos))
+	}
+
+	var changesOutput []string
+	for _, change := range changes {
+		changesOutput = append(changesOutput, change.ToString())
+	}
+	fmt.Printf("%s %d %s\n\n%s\n\n", au.Bold(au.Gray("Found")), au.Bold(au.Brown(len(changes))), au.Bold(au.Gray("changes to apply:")), strings.Join(changesOutput, "\n"))
+
+	if options.DryRun {
+		fmt.Println(au.Bold(au.Green("DRY RUN - no webhooks were changed")))
+		return scanError(ctx, failedRepos, len(spec.Repos))
+	}
+
+	// Creates and updates can be re-applied but deletes cannot be reverted
+	if deletes > 0 {
+		question := fmt.Sprintf("%s %s\n", au.Bold(fmt.Sprintf("Do you wish to apply the changes? %d webhook(s) will be destroyed and", deletes)), au.Bold(au.Red("cannot be recovered.")))
+		if !confirmPassPhrase(question, "apply", options.Yes) {
+			fmt.Println(au.Green("\nApply aborted."))
+			return scanError(ctx, failedRepos, len(spec.Repos))
+		}
+	}
+
+	failed := 0
+	for _, change := range changes {
+		if err := applyChangeToRepo(ctx, change); err != nil {
+			fmt.Printf("- %s %s : %s\n", au.Red("Error applying change"), change.ToString(), au.Red(err))
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to apply %d of %d changes", failed, len(changes))
+	}
+	fmt.Println(au.Green("\nApply completed."))
+	return scanError(ctx, failedRepos, len(spec.Repos))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditLog appends a line per destroyed or deactivated webhook to a file so bulk
+// destroys leave a durable record. A nil AuditLog records nothing.
+type AuditLog struct {
+	file *os.File
+}
+
+// openAuditLog opens an audit log file for appending, creating it if needed
+// @arg filePath string - Empty to not keep an audit log
+// @return *AuditLog
+// @return error
+func openAuditLog(filePath string) (*AuditLog, error) {
+	if filePath == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: file}, nil
+}
+
+// record appends a tab separated line of the time, repo, hook ID, config URL
+// and result of destroying or deactivating a webhook
+// @arg hook WebHook
+// @arg result string - Recorded on success e.g. destroyed
+// @arg actionErr error - Error changing the webhook, or nil if it succeeded
+func (l *AuditLog) record(hook WebHook, result string, actionErr error) {
+	if l == nil {
+		return
+	}
+	if actionErr != nil {
+		// Keep each record on a single line
+		result = "failed: " + strings.Join(strings.Fields(actionErr.Error()), " ")
+	}
+	line := strings.Join([]string{
+		time.Now().UTC().Format(time.RFC3339),
+		hook.Repo,
+		strconv.Itoa(hook.ID),
+		hook.Config.URL,
+		result,
+	}, "\t")
+	if _, err := fmt.Fprintln(l.file, line); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s\n", au.Red("Error writing to audit log:"), au.Red(err))
+	}
+}
+
+// Close closes the audit log file
+// @return error
+func (l *AuditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
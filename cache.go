@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultCacheTTL is how long cached webhooks are reused for
+const defaultCacheTTL = 10 * time.Minute
+
+// cacheDir is the directory responses listing webhooks are cached in. Empty disables the cache.
+var cacheDir string
+
+// cacheTTL is how long a cached response is reused for
+var cacheTTL = defaultCacheTTL
+
+// dirCache caches lists of webhooks in a file per request URL of cacheDir
+type dirCache struct{}
+
+// cachePath returns the path of the cache file of a request URL
+// @arg requestURL string
+// @return string
+func cachePath(requestURL string) string {
+	sum := sha256.Sum256([]byte(requestURL))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get returns the cached response of a request URL if it is younger than cacheTTL
+// @arg requestURL string
+// @return []byte
+// @return bool - Whether a fresh response was cached
+func (dirCache) Get(requestURL string) ([]byte, bool) {
+	filePath := cachePath(requestURL)
+	info, err := os.Stat(filePath)
+	if err != nil || time.Since(info.ModTime()) > cacheTTL {
+		return nil, false
+	}
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, false
+	}
+	return contents, true
+}
+
+// Delete removes the cached response of a request URL. Failures are only logged.
+// @arg requestURL string
+func (dirCache) Delete(requestURL string) {
+	if err := os.Remove(cachePath(requestURL)); err != nil && !os.IsNotExist(err) {
+		logVerbose(1, "Could not remove cache: %v", err)
+	}
+}
+
+// Put caches the response of a request URL. Failures are only logged as
+// the cache is an optimisation.
+// @arg requestURL string
+// @arg response []byte
+func (dirCache) Put(requestURL string, response []byte) {
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		logVerbose(1, "Could not create cache directory: %v", err)
+		return
+	}
+	if err := writeFileAtomic(cachePath(requestURL), response, 0600); err != nil {
+		logVerbose(1, "Could not write cache: %v", err)
+	}
+}
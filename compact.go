@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// compactOutput prints each hook in text output as a single line of aligned
+// columns instead of a block per repo
+var compactOutput bool
+
+// compactHook returns the columns of a hook in compact output: repo, config url,
+// code, message and flags, separated by tabs and ending in a newline. Every cell
+// of a column is wrapped in a single colour so alignment is unaffected by ANSI codes.
+// @arg repo string - Name of the repo owning the hook
+// @arg hook *HookWrapper
+// @return string
+func compactHook(repo string, hook *HookWrapper) string {
+	code, message, colour := hookStatus(hook.Hook)
+	// Keep each hook on one line
+	message = strings.Join(strings.Fields(message), " ")
+
+	flags := hook.tags()
+	if showHookAge && !hook.Hook.NoStatus {
+		flags = append([]string{fmt.Sprint(au.Brown("updated " + formatAge(time.Since(hook.Hook.UpdatedAt)) + " ago"))}, flags...)
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n", au.Magenta(repo), hookConfigURL(hook.Hook), colour(code), colour(message), strings.Join(flags, " "))
+}
+
+// alignColumns aligns the tab separated columns of lines returned by compactHook
+// @arg text string
+// @return string
+func alignColumns(text string) string {
+	var output strings.Builder
+	writer := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+	fmt.Fprint(writer, text)
+	writer.Flush()
+
+	// Drop the padding left after the last column of hooks without flags
+	lines := strings.Split(output.String(), "\n")
+	for index, line := range lines {
+		lines[index] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
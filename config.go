@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// loadConfigFile sets flag values from a JSON config file of flag names to values
+// e.g. {"t": "4XX,5XX", "delay": "100ms"}. YAML is not supported. Flags set on the command line take
+// precedence over the file so must be parsed before this is called.
+// @arg filePath string
+// @return error
+func loadConfigFile(filePath string) error {
+	jsonBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	// Numbers are decoded as written so large ones aren't printed in exponent form
+	config := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&config); err != nil {
+		return err
+	}
+
+	// Flags explicitly set on the command line
+	setFlags := explicitFlags()
+
+	for name, value := range config {
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q", name)
+		}
+		if setFlags[name] {
+			continue
+		}
+		if err := flag.Set(name, configValueToString(value)); err != nil {
+			return fmt.Errorf("invalid value for option %q: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// explicitFlags returns the names of the flags that have been set, on the command
+// line or by the config file, as opposed to left at their default
+// @return map[string]bool
+func explicitFlags() map[string]bool {
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	return setFlags
+}
+
+// configValueToString converts a JSON config value to its flag string form.
+// Arrays are joined into a CSV string. Numbers must be decoded as json.Number.
+// @arg value interface{}
+// @return string
+func configValueToString(value interface{}) string {
+	if values, ok := value.([]interface{}); ok {
+		strs := make([]string, len(values))
+		for i, item := range values {
+			strs[i] = fmt.Sprint(item)
+		}
+		return strings.Join(strs, ",")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// CreateRow is a webhook to create, read from a row of a CSV file
+type CreateRow struct {
+	// Line is the line of the row in the file, for reporting
+	Line        int
+	Repo        string
+	URL         string
+	Events      []string
+	ContentType string
+}
+
+// validate returns an error describing why the row cannot be created, if any
+// @return error
+func (r CreateRow) validate() error {
+	switch {
+	case r.Repo == "":
+		return fmt.Errorf("no repo")
+	case strings.Count(r.Repo, "/") != 1:
+		return fmt.Errorf("repo %q must be namespace/repo", r.Repo)
+	case r.URL == "":
+		return fmt.Errorf("no url")
+	case r.ContentType != "json" && r.ContentType != "form":
+		return fmt.Errorf("content type %q must be json or form", r.ContentType)
+	}
+	return nil
+}
+
+// readCreateCSV reads the webhooks to create from a CSV file with a header row
+// naming the repo, url, events and content_type columns in any order. Only
+// repo and url are required. Events may be separated by commas, semicolons or
+// spaces and default to push. The content type defaults to json.
+// @arg filepath string
+// @return []CreateRow
+// @return error
+func readCreateCSV(filepath string) ([]CreateRow, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	// Rows are validated individually so columns may be left off the end
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for index, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = index
+	}
+	for _, required := range []string{"repo", "url"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the header row has no %s column", required)
+		}
+	}
+
+	var rows []CreateRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		// field returns the trimmed value of a column, or an empty string if it is missing
+		field := func(name string) string {
+			index, ok := columns[name]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		row := CreateRow{
+			Line:        line,
+			Repo:        field("repo"),
+			URL:         field("url"),
+			Events:      strings.FieldsFunc(field("events"), func(r rune) bool { return r == ',' || r == ';' || r == ' ' }),
+			ContentType: strings.ToLower(field("content_type")),
+		}
+		// Skip blank rows
+		if row.Repo == "" && row.URL == "" && len(row.Events) == 0 && row.ContentType == "" {
+			continue
+		}
+		if len(row.Events) == 0 {
+			row.Events = []string{"push"}
+		}
+		if row.ContentType == "" {
+			row.ContentType = "json"
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Executes the creation of a webhook for each row of a CSV file. Rows whose
+// config URL already exists on their repo are skipped.
+// @arg ctx context.Context - Cancels requests when done
+// @arg filepath string
+// @arg dryRun bool - Report the rows that would be created without creating them
+// @return error
+func executeCreateCSV(ctx context.Context, filepath string, dryRun bool) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("         C R E A T E   C S V")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	rows, err := readCreateCSV(filepath)
+	if err != nil {
+		printError("Issue reading CSV file:", err)
+	}
+
+	fmt.Println(au.Bold(au.Gray(fmt.Sprintf("Creating %d webhook(s) from %s...\n", len(rows), filepath))))
+
+	// Normalized config URLs of the hooks currently present on each repo
+	existingURLs := make(map[string]map[string]bool)
+	created, skipped, failed := 0, 0, 0
+
+	for index, row := range rows {
+		if ctx.Err() != nil {
+			fmt.Printf("%s\n", au.Red(fmt.Sprintf("Deadline reached after %d of %d row(s). Remaining rows were skipped.", index, len(rows))))
+			break
+		}
+		prefix := fmt.Sprintf("%s %s =>", au.Gray(fmt.Sprintf("line %d:", row.Line)), au.Bold(au.Magenta(row.Repo)))
+		if err := row.validate(); err != nil {
+			fmt.Printf("%s %s %s\n", prefix, au.Red("Invalid row:"), au.Red(err))
+			failed++
+			continue
+		}
+
+		// Fetch the current hooks of the repo the first time it is seen
+		if _, ok := existingURLs[row.Repo]; !ok {
+			webHooks, err := getWebHooks(ctx, Repo{Name: row.Repo})
+			if err != nil {
+				fmt.Printf("%s %s %s\n", prefix, au.Red("Failed to retrieve web hooks:"), au.Red(err))
+				failed++
+				continue
+			}
+			existingURLs[row.Repo] = make(map[string]bool, len(webHooks.Hooks))
+			for _, existing := range webHooks.Hooks {
+				existingURLs[row.Repo][webhookit.NormalizeConfigURL(existing.Config.URL)] = true
+			}
+		}
+
+		if existingURLs[row.Repo][webhookit.NormalizeConfigURL(row.URL)] {
+			fmt.Printf("%s %s\n", prefix, au.Gray(row.URL+" already exists, skipping"))
+			skipped++
+			continue
+		}
+
+		if dryRun {
+			fmt.Printf("%s %s\n", prefix, au.Brown(fmt.Sprintf("%s would be created for %s", row.URL, strings.Join(row.Events, ","))))
+			existingURLs[row.Repo][webhookit.NormalizeConfigURL(row.URL)] = true
+			created++
+			continue
+		}
+
+		hook := WebHook{Active: true, Events: row.Events}
+		hook.Config.URL = row.URL
+		hook.Config.ContentType = row.ContentType
+		if err := createWebHook(ctx, Repo{Name: row.Repo}, hook); err != nil {
+			fmt.Printf("%s %s %s\n", prefix, au.Red("Error creating "+row.URL+":"), au.Red(err))
+			failed++
+			continue
+		}
+		existingURLs[row.Repo][webhookit.NormalizeConfigURL(row.URL)] = true
+		fmt.Printf("%s %s\n", prefix, au.Green(row.URL+" created"))
+		created++
+	}
+
+	createdLabel := "created,"
+	if dryRun {
+		fmt.Println(au.Bold(au.Green("\nDRY RUN - no webhooks were created")))
+		createdLabel = "to create,"
+	}
+	fmt.Printf("\n%s %d %s %d %s %d %s\n", au.Green("Create complete."), au.Bold(au.Green(created)), au.Gray(createdLabel), au.Bold(au.Brown(skipped)), au.Gray("skipped,"), au.Bold(au.Red(failed)), au.Gray("failed"))
+	if failed > 0 {
+		return fmt.Errorf("failed to create %d of %d row(s)", failed, len(rows))
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// recentDeliveries is the number of the most recent deliveries of each webhook reported on
+const recentDeliveries = 30
+
+// defaultSlowThreshold is the response time above which a receiver is considered slow.
+// GitHub gives up on deliveries after 10 seconds.
+const defaultSlowThreshold = 5 * time.Second
+
+// Delivery is a single delivery of a webhook in the form of what is returned
+// from a GitHub API call
+type Delivery struct {
+	ID          int       `json:"id"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	// Duration is the response time of the receiver in seconds
+	Duration   float64 `json:"duration"`
+	Status     string  `json:"status"`
+	StatusCode int     `json:"status_code"`
+	Event      string  `json:"event"`
+}
+
+// responseTime returns the response time of the delivery
+// @return time.Duration
+func (d Delivery) responseTime() time.Duration {
+	return time.Duration(d.Duration * float64(time.Second))
+}
+
+// getDeliveries retrieves the most recent deliveries of a webhook, newest first
+// @arg ctx context.Context - Cancels requests when done
+// @arg hook WebHook
+// @return []Delivery
+// @return error
+func getDeliveries(ctx context.Context, hook WebHook) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := apiClient.Request(ctx, hook.URL+"/deliveries?per_page="+strconv.Itoa(recentDeliveries), "GET", nil, &deliveries)
+	return deliveries, err
+}
+
+// DeliveryStats summarises the response times of the deliveries of a webhook
+type DeliveryStats struct {
+	Count   int
+	Average time.Duration
+	Last    Delivery
+}
+
+// deliveryStats summarises deliveries ordered newest first
+// @arg deliveries []Delivery
+// @return DeliveryStats
+func deliveryStats(deliveries []Delivery) DeliveryStats {
+	if len(deliveries) == 0 {
+		return DeliveryStats{}
+	}
+	var total time.Duration
+	for _, delivery := range deliveries {
+		total += delivery.responseTime()
+	}
+	return DeliveryStats{
+		Count:   len(deliveries),
+		Average: total / time.Duration(len(deliveries)),
+		Last:    deliveries[0],
+	}
+}
+
+// isSlow returns whether the average or last response time exceeds the threshold
+// @arg threshold time.Duration
+// @return bool
+func (s DeliveryStats) isSlow(threshold time.Duration) bool {
+	return s.Count > 0 && (s.Average > threshold || s.Last.responseTime() > threshold)
+}
+
+// ToString returns a formatted string of the response times
+// @arg threshold time.Duration - Response time above which a receiver is slow
+func (s DeliveryStats) ToString(threshold time.Duration) string {
+	if s.Count == 0 {
+		return fmt.Sprint(au.Gray("No recent deliveries"))
+	}
+	output := fmt.Sprintf("%d deliveries | average %s | last %s (%d)", s.Count, s.Average.Round(time.Millisecond), s.Last.responseTime().Round(time.Millisecond), s.Last.StatusCode)
+	if s.isSlow(threshold) {
+		return fmt.Sprintf("%s %s", au.Red(output), au.Bold(au.Red("[SLOW]")))
+	}
+	return fmt.Sprint(au.Green(output))
+}
+
+// Executes a report of the response times of the recent deliveries of webhooks
+// @arg ctx context.Context - Cancels requests when done
+// @arg slowThreshold time.Duration - Response time above which a receiver is flagged as slow
+// @arg filter HookFilter
+// @return error
+func executeDeliveries(ctx context.Context, slowThreshold time.Duration, filter HookFilter) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("         D E L I V E R I E S")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	fmt.Printf("%s %s\n\n", au.Bold(au.Gray("Checking response times of recent deliveries. Receivers are slow above")), au.Bold(au.Brown(slowThreshold)))
+
+	hookCount, slowCount, failedRepos := 0, 0, 0
+
+	// For each repo...
+	for index, repo := range reposContainer.Repos {
+		if deadlineReached(ctx, index, len(reposContainer.Repos)) {
+			break
+		}
+		printProgress(index, len(reposContainer.Repos), repo.Name)
+
+		// Get web hooks
+		webHooks, err := getWebHooks(ctx, repo)
+		if err != nil {
+			clearProgress()
+			fmt.Print(repoErrorMessage(err))
+			if isRepoFailure(err) {
+				failedRepos++
+			}
+			continue
+		}
+		webHooks.Hooks = webhookit.FilterWebHooks(webHooks.Hooks, filter)
+
+		repoOutput := fmt.Sprintf("%s\n\n", au.Bold(au.Magenta(repo.Name)))
+		for _, hook := range webHooks.Hooks {
+			deliveries, err := getDeliveries(ctx, hook)
+			if err != nil {
+				repoOutput += fmt.Sprintf("%s => %s %s\n", hook.Config.URL, au.Red("Failed to retrieve deliveries:"), au.Red(err))
+				continue
+			}
+			stats := deliveryStats(deliveries)
+			if stats.isSlow(slowThreshold) {
+				slowCount++
+			}
+			hookCount++
+			repoOutput += fmt.Sprintf("%s => %s\n", hook.Config.URL, stats.ToString(slowThreshold))
+		}
+
+		clearProgress()
+		fmt.Println(repoOutput)
+	}
+
+	clearProgress()
+
+	fmt.Printf("%s %d %s %d %s\n", au.Green("Deliveries complete."), au.Bold(hookCount), au.Gray("webhooks checked,"), au.Bold(au.Red(slowCount)), au.Gray("slow"))
+	return scanError(ctx, failedRepos, len(reposContainer.Repos))
+}
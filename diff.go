@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// HookChange is a webhook of a repo that differs between a backup and the current hooks
+type HookChange struct {
+	Before WebHook
+	After  WebHook
+	// Fields are the names of the WebHook fields that differ
+	Fields []string
+}
+
+// changedFields returns the names of the fields of a webhook that were changed.
+// Fields set by GitHub such as the last response are ignored.
+// @arg before WebHook
+// @arg after WebHook
+// @return []string
+func changedFields(before, after WebHook) []string {
+	var fields []string
+	if compareStringArrays(sortedCopy(before.Events), sortedCopy(after.Events)) {
+		fields = append(fields, "Events")
+	}
+	if before.Active != after.Active {
+		fields = append(fields, "Active")
+	}
+	if !reflect.DeepEqual(before.Config, after.Config) {
+		fields = append(fields, "Config")
+	}
+	return fields
+}
+
+// sortedCopy returns a sorted copy of a string array so the order of values is ignored
+// @arg values []string
+// @return []string
+func sortedCopy(values []string) []string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// diffWebHooks compares the webhooks of a repo in a backup to its current
+// webhooks. Webhooks are paired by normalized config URL.
+// @arg before []WebHook - Webhooks in the backup
+// @arg after []WebHook - Current webhooks
+// @return []WebHook - Added webhooks
+// @return []WebHook - Removed webhooks
+// @return []HookChange - Modified webhooks
+func diffWebHooks(before, after []WebHook) ([]WebHook, []WebHook, []HookChange) {
+	var added, removed []WebHook
+	var modified []HookChange
+
+	// Backup hooks not yet paired with a current hook, by config URL
+	unpaired := make(map[string][]WebHook)
+	for _, hook := range before {
+		key := webhookit.NormalizeConfigURL(hook.Config.URL)
+		unpaired[key] = append(unpaired[key], hook)
+	}
+
+	for _, hook := range after {
+		key := webhookit.NormalizeConfigURL(hook.Config.URL)
+		if len(unpaired[key]) == 0 {
+			added = append(added, hook)
+			continue
+		}
+		previous := unpaired[key][0]
+		unpaired[key] = unpaired[key][1:]
+		if fields := changedFields(previous, hook); len(fields) > 0 {
+			modified = append(modified, HookChange{Before: previous, After: hook, Fields: fields})
+		}
+	}
+
+	// Keep the order of the backup for removed hooks
+	for _, hook := range before {
+		key := webhookit.NormalizeConfigURL(hook.Config.URL)
+		if len(unpaired[key]) > 0 {
+			removed = append(removed, unpaired[key][0])
+			unpaired[key] = unpaired[key][1:]
+		}
+	}
+	return added, removed, modified
+}
+
+// Executes a comparison of the current webhooks of the repos in a backup to
+// the webhooks in the backup
+// @arg ctx context.Context - Cancels requests when done
+// @arg filepath string - Path of a backup written with -b
+// @return error
+func executeDiff(ctx context.Context, filepath string) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("               D I F F")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	backup, err := readBackup(filepath)
+	if err != nil {
+		printError("Issue reading backup file:", err)
+	}
+
+	// Group the backup hooks by repo, keeping the order repos first appear in
+	var repoNames []string
+	backupHooks := make(map[string][]WebHook)
+	for _, hook := range backup.Hooks {
+		if hook.Repo == "" {
+			fmt.Printf("%s %s\n", au.Red("Skipping hook with no owning repo:"), au.Red(hook.URL))
+			continue
+		}
+		if _, ok := backupHooks[hook.Repo]; !ok {
+			repoNames = append(repoNames, hook.Repo)
+		}
+		backupHooks[hook.Repo] = append(backupHooks[hook.Repo], hook)
+	}
+
+	fmt.Println(au.Bold(au.Gray(fmt.Sprintf("Comparing the webhooks of %d repo(s) to %s...\n", len(repoNames), filepath))))
+
+	addedCount, removedCount, modifiedCount, failedRepos := 0, 0, 0, 0
+
+	// For each repo...
+	for index, repoName := range repoNames {
+		if deadlineReached(ctx, index, len(repoNames)) {
+			break
+		}
+		printProgress(index, len(repoNames), repoName)
+
+		// Get web hooks
+		webHooks, err := getWebHooks(ctx, Repo{Name: repoName})
+		if err != nil {
+			clearProgress()
+			fmt.Print(repoErrorMessage(err))
+			if isRepoFailure(err) {
+				failedRepos++
+			}
+			continue
+		}
+
+		added, removed, modified := diffWebHooks(backupHooks[repoName], webHooks.Hooks)
+		addedCount += len(added)
+		removedCount += len(removed)
+		modifiedCount += len(modified)
+
+		clearProgress()
+		if len(added)+len(removed)+len(modified) == 0 {
+			fmt.Printf("%s %s\n\n", au.Bold(au.Magenta(repoName)), au.Green("unchanged"))
+			continue
+		}
+		fmt.Printf("%s\n\n", au.Bold(au.Magenta(repoName)))
+		for _, hook := range added {
+			fmt.Printf("%s %s [%s]\n", au.Bold(au.Green("[ADDED]   ")), hook.Config.URL, strings.Join(hook.Events, ", "))
+		}
+		for _, hook := range removed {
+			fmt.Printf("%s %s [%s]\n", au.Bold(au.Red("[REMOVED] ")), hook.Config.URL, strings.Join(hook.Events, ", "))
+		}
+		for _, change := range modified {
+			fmt.Printf("%s %s %s\n\n", au.Bold(au.Brown("[MODIFIED]")), change.After.Config.URL, au.Gray("(0: backup, 1: current)"))
+			for _, output := range fieldDiff([]WebHook{change.Before, change.After}, change.Fields) {
+				fmt.Println(output)
+			}
+		}
+		fmt.Println()
+	}
+
+	clearProgress()
+
+	fmt.Printf("%s %d %s %d %s %d %s\n", au.Green("Diff complete."), au.Bold(au.Green(addedCount)), au.Gray("added,"), au.Bold(au.Red(removedCount)), au.Gray("removed,"), au.Bold(au.Brown(modifiedCount)), au.Gray("modified"))
+	return scanError(ctx, failedRepos, len(repoNames))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// Executes a report of every config URL used by more than one webhook of a repo
+// @arg ctx context.Context - Cancels requests when done
+// @arg filter HookFilter
+// @return error
+func executeDuplicateReport(ctx context.Context, filter HookFilter) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("     D U P L I C A T E   R E P O R T")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	fmt.Println(au.Bold(au.Gray("Checking GitHub repo(s) for duplicate webhooks...\n")))
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "REPO\tCONFIG URL\tHOOK IDS")
+
+	groupCount, hookCount, failedRepos := 0, 0, 0
+
+	// For each repo...
+	for index, repo := range reposContainer.Repos {
+		if deadlineReached(ctx, index, len(reposContainer.Repos)) {
+			break
+		}
+		printProgress(index, len(reposContainer.Repos), repo.Name)
+
+		// Get web hooks
+		webHooks, err := getWebHooks(ctx, repo)
+		if err != nil {
+			clearProgress()
+			fmt.Print(repoErrorMessage(err))
+			if isRepoFailure(err) {
+				failedRepos++
+			}
+			continue
+		}
+		webHooks.Hooks = webhookit.FilterWebHooks(webHooks.Hooks, filter)
+
+		for _, group := range groupDuplicates(wrapWebHooks(webHooks)) {
+			ids := make([]string, len(group))
+			for i, hook := range group {
+				ids[i] = strconv.Itoa(hook.Hook.ID)
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\n", repo.Name, group[0].Hook.Config.URL, strings.Join(ids, ", "))
+			groupCount++
+			hookCount += len(group)
+		}
+	}
+
+	clearProgress()
+
+	if groupCount == 0 {
+		fmt.Println(au.Green("Found no duplicate webhooks."))
+		return scanError(ctx, failedRepos, len(reposContainer.Repos))
+	}
+
+	table.Flush()
+	fmt.Printf("\n%s %d %s %d %s\n", au.Bold(au.Gray("Found")), au.Bold(au.Brown(groupCount)), au.Bold(au.Gray("duplicated config url(s) across")), au.Bold(au.Brown(hookCount)), au.Bold(au.Gray("hooks")))
+	return scanError(ctx, failedRepos, len(reposContainer.Repos))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// tokenRefreshMargin is how long before expiry an installation token is refreshed
+const tokenRefreshMargin = 5 * time.Minute
+
+// GitHubApp authenticates as an installation of a GitHub App using short-lived
+// installation tokens, which are refreshed as they near expiry
+type GitHubApp struct {
+	AppID          int64
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+
+	token     string
+	expiresAt time.Time
+}
+
+// loadGitHubApp reads the PEM private key of a GitHub App
+// @arg appID int64
+// @arg installationID int64
+// @arg privateKeyPath string
+// @return *GitHubApp
+// @return error
+func loadGitHubApp(appID, installationID int64, privateKeyPath string) (*GitHubApp, error) {
+	pemBytes, err := ioutil.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found in %s", privateKeyPath)
+	}
+
+	// GitHub issues PKCS#1 keys but PKCS#8 keys are accepted too
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		key, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, fmt.Errorf("private key in %s is not an RSA key: %v", privateKeyPath, err)
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key in %s is not an RSA key", privateKeyPath)
+		}
+		privateKey = rsaKey
+	}
+
+	return &GitHubApp{AppID: appID, InstallationID: installationID, PrivateKey: privateKey}, nil
+}
+
+// jwt generates a JSON Web Token signed with the private key of the app, used
+// to request installation tokens
+// @return string
+// @return error
+func (a *GitHubApp) jwt() (string, error) {
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		// Backdated to allow for clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		// GitHub allows at most 10 minutes
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.AppID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// refresh exchanges a JWT for a new installation token
+// @arg ctx context.Context - Cancels requests when done
+// @return error
+func (a *GitHubApp) refresh(ctx context.Context) error {
+	jwt, err := a.jwt()
+	if err != nil {
+		return err
+	}
+
+	requestURL := fmt.Sprintf("%s/app/installations/%d/access_tokens", apiClient.APIURL(), a.InstallationID)
+	request, err := http.NewRequestWithContext(ctx, "POST", requestURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Add("Authorization", "Bearer "+jwt)
+	request.Header.Add("Accept", "application/vnd.github+json")
+
+	response, err := apiClient.Send(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 201 {
+		return webhookit.NewAPIError(response)
+	}
+
+	var installationToken struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&installationToken); err != nil {
+		return err
+	}
+	if installationToken.Token == "" {
+		return errors.New("no installation token returned")
+	}
+	a.token = installationToken.Token
+	a.expiresAt = installationToken.ExpiresAt
+	return nil
+}
+
+// installationToken returns the current installation token, refreshing it first
+// if it is missing or nears expiry
+// @arg ctx context.Context - Cancels requests when done
+// @return string
+// @return error - Why the token could not be refreshed
+func (a *GitHubApp) installationToken(ctx context.Context) (string, error) {
+	if a.token == "" || time.Until(a.expiresAt) < tokenRefreshMargin {
+		logVerbose(1, "Refreshing GitHub App installation token")
+		if err := a.refresh(ctx); err != nil {
+			// Not wrapped so the status of the token request is not taken for that of the request being made
+			return "", fmt.Errorf("issue refreshing GitHub App installation token: %v", err)
+		}
+	}
+	return a.token, nil
+}
+
+// authorization returns the Authorization header value of API requests made as
+// the installation. A failed refresh fails the request being made.
+// @arg ctx context.Context
+// @return string
+// @return error
+func (a *GitHubApp) authorization(ctx context.Context) (string, error) {
+	token, err := a.installationToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "token " + token, nil
+}
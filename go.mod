@@ -0,0 +1,5 @@
+module github.com/eimlav/webhookit
+
+go 1.21
+
+require github.com/logrusorgru/aurora v0.0.0-20181002194514-a7b3b318ed4e
@@ -1,38 +1,82 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"math/rand"
+	"math/big"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	. "github.com/logrusorgru/aurora"
+	"github.com/eimlav/webhookit/pkg/webhookit"
+	"github.com/logrusorgru/aurora"
 )
 
 var apiKey = os.Getenv("WEBHOOKIT_API_KEY")
 
 const (
-	requestDelay time.Duration = 50 * time.Millisecond
+	defaultRequestDelay time.Duration = 50 * time.Millisecond
+	defaultMaxRetries   int           = 3
+	defaultTimeout      int           = 10
+
+	// defaultConfirmLength is the default length of confirmation passphrases
+	defaultConfirmLength = 8
+
+	// exitBrokenHooks is the exit code used when -fail-on-broken finds broken hooks
+	exitBrokenHooks = 2
 )
 
-// ResponseJSON is the type representing an API response
-type ResponseJSON []struct {
-	data string
-}
+// errBrokenHooks is returned by executeCheck when broken hooks are found and FailOnBroken is set
+var errBrokenHooks = errors.New("broken webhooks found")
+
+// requestDelay is the minimum time between consecutive API requests. A delay of 0 disables throttling.
+var requestDelay = defaultRequestDelay
+
+// repoTimeout bounds the time spent retrieving the webhooks of each repo, across
+// every page of the list and every retry of each page. Cached webhooks are read
+// without a request. A repo taking longer is skipped. Zero disables it.
+var repoTimeout time.Duration
+
+// confirmLength is the number of letters in confirmation passphrases
+var confirmLength = defaultConfirmLength
+
+// knownPassPhrase replaces the random confirmation passphrase so a script can supply
+// it on stdin. Empty uses a random passphrase.
+var knownPassPhrase string
+
+// maxRetries is the number of times a rate limited or failed request is retried before giving up
+var maxRetries = defaultMaxRetries
+
+// verbosity is the level of request logging to stderr. 1 logs requests and
+// responses, 2 also logs the bodies of failed responses.
+var verbosity int
+
+// ResponseJSON is an API response kept undecoded. Passing a *ResponseJSON as the
+// output of apiClient.Request captures the body exactly as returned.
+type ResponseJSON = json.RawMessage
 
 // Repo is the type representing a single repo
-type Repo struct {
-	Name string `json:"name"`
-}
+type Repo = webhookit.Repo
 
 // ReposContainer is the type representing all repos
 type ReposContainer struct {
@@ -40,7 +84,95 @@ type ReposContainer struct {
 }
 
 var reposContainer ReposContainer
-var client = &http.Client{Timeout: 10 * time.Second}
+
+// transport is the transport of the shared client, configured by main with any proxy settings
+var transport = http.DefaultTransport.(*http.Transport).Clone()
+var client = &http.Client{Timeout: time.Duration(defaultTimeout) * time.Second, Transport: transport}
+
+// apiClient makes every API request. It is replaced by main once the flags are parsed.
+var apiClient = webhookit.NewClient(apiKey)
+
+// apiVersion pins the version of the GitHub REST API with the X-GitHub-Api-Version
+// header so changes to the API do not break requests. Empty sends no header.
+var apiVersion = webhookit.DefaultAPIVersion
+
+// apiVersionRegex matches the dates GitHub names versions of its API by
+var apiVersionRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// customHeaders are added to every API request, e.g. for a gateway in front of the API
+var customHeaders = headerFlag{}
+
+// au colours output. Colouring is disabled by main when requested or when stdout is not a terminal.
+var au = aurora.NewAurora(true)
+
+// showHookAge adds the time since each hook was last updated to statusToString
+var showHookAge bool
+
+// showProgress prints the repo being scanned to stderr. It is enabled by main
+// for text output when stderr is a terminal.
+var showProgress bool
+
+// infoOutput receives informational messages. It is switched to stderr when
+// results are written to stdout in a machine-readable format.
+var infoOutput io.Writer = os.Stdout
+
+// CheckResult is the machine-readable representation of a checked webhook
+type CheckResult struct {
+	Repo      string `json:"repo"`
+	HookID    int    `json:"hook_id"`
+	HookURL   string `json:"hook_url"`
+	ConfigURL string `json:"config_url"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	Active    bool   `json:"active"`
+	Duplicate bool   `json:"duplicate"`
+	Secret    bool   `json:"secret"`
+	// EventsMismatch is set when the events of the hook differ from -expect-events
+	EventsMismatch bool `json:"events_mismatch"`
+}
+
+// ansiEscapeRegex matches the ANSI escape codes used to colour output
+var ansiEscapeRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// stripANSI removes colour codes from text
+// @arg text string
+// @return string
+func stripANSI(text string) string {
+	return ansiEscapeRegex.ReplaceAllString(text, "")
+}
+
+// checkResultsCSVHeader is the header row of CSV check results
+var checkResultsCSVHeader = []string{"repo", "hook_id", "config_url", "code", "message", "active", "duplicate", "secret", "events_mismatch"}
+
+// csvRecord converts a check result to a CSV row matching checkResultsCSVHeader
+// @return []string
+func (result CheckResult) csvRecord() []string {
+	return []string{
+		result.Repo,
+		strconv.Itoa(result.HookID),
+		result.ConfigURL,
+		strconv.Itoa(result.Code),
+		result.Message,
+		strconv.FormatBool(result.Active),
+		strconv.FormatBool(result.Duplicate),
+		strconv.FormatBool(result.Secret),
+		strconv.FormatBool(result.EventsMismatch),
+	}
+}
+
+// writeCheckResultsCSV writes check results as CSV with a header row
+// @arg writer io.Writer
+// @arg results []CheckResult
+// @return error
+func writeCheckResultsCSV(writer io.Writer, results []CheckResult) error {
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write(checkResultsCSVHeader)
+	for _, result := range results {
+		csvWriter.Write(result.csvRecord())
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
 
 // WebHooks is an array of WebHooks
 type WebHooks struct {
@@ -49,25 +181,25 @@ type WebHooks struct {
 
 // WebHook is the type representing a single webhook in the form
 // of what is returned from a GitHub API call
-type WebHook struct {
-	ID      int      `json:"id"`
-	URL     string   `json:"url"`
-	TestURL string   `json:"test_url"`
-	PingURL string   `json:"ping_url"`
-	Name    string   `json:"name"`
-	Events  []string `json:"events"`
-	Active  bool     `json:"active"`
-	Config  struct {
-		URL         string `json:"url"`
-		ContentType string `json:"content_type"`
-	} `json:"config"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	CreatedAt    time.Time `json:"created_at"`
-	LastResponse struct {
-		Code    int    `json:"code"`
-		Status  string `json:"status"`
-		Message string `json:"message"`
-	} `json:"last_response"`
+type WebHook = webhookit.WebHook
+
+// HookFilter limits which webhooks are considered by check and destroy
+type HookFilter = webhookit.HookFilter
+
+// parseDate parses a time in RFC3339 format or a date in YYYY-MM-DD format,
+// which is taken as midnight UTC
+// @arg value string
+// @return time.Time
+// @return error
+func parseDate(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not in RFC3339 or YYYY-MM-DD format", value)
+	}
+	return parsed, nil
 }
 
 // HookWrapper is used to track webhooks in the executeDestroy method
@@ -76,7 +208,10 @@ type HookWrapper struct {
 	Duplicate   bool
 	DestroySkip bool
 	Destroy     bool
-	Code        string
+	// Deactivate marks that the hook is deactivated rather than destroyed
+	Deactivate bool
+	// EventsMismatch marks that the events of the hook differ from the expected set
+	EventsMismatch bool
 }
 
 // canDestroy returns whether an item can be destroyed
@@ -87,414 +222,1264 @@ func (d HookWrapper) canDestroy() bool {
 
 // ToString prints the string of a HookWrapper
 func (d HookWrapper) ToString() string {
-	output := ""
+	output := statusToString(d.Hook)
+	for _, tag := range d.tags() {
+		output += " " + tag
+	}
+	return output
+}
+
+// tags returns the coloured tags of a HookWrapper e.g. [DUPLICATE]
+// @return []string
+func (d HookWrapper) tags() []string {
+	var tags []string
 	if d.Duplicate {
-		output += fmt.Sprint(Cyan(" [DUPLICATE]"))
+		tags = append(tags, fmt.Sprint(au.Cyan("[DUPLICATE]")))
 	}
-	if d.canDestroy() {
-		output += fmt.Sprint(Brown(" [TO BE DESTROYED]"))
+	if d.canDestroy() && d.Deactivate {
+		tags = append(tags, fmt.Sprint(au.Brown("[TO BE DEACTIVATED]")))
+	} else if d.canDestroy() {
+		tags = append(tags, fmt.Sprint(au.Brown("[TO BE DESTROYED]")))
 	}
-	return d.Hook.StatusToString() + output
+	if d.Hook.IsInsecure() {
+		tags = append(tags, fmt.Sprint(au.Red("[NO SECRET CONFIGURED]")))
+	}
+	if d.EventsMismatch {
+		tags = append(tags, fmt.Sprint(au.Magenta("[EVENTS MISMATCH: "+strings.Join(d.Hook.Events, ",")+"]")))
+	}
+	return tags
 }
 
-// StatusToString returns a formatted string of the status of the web hook
-func (w WebHook) StatusToString() (status string) {
-	// Required for edge cases where w.Config.URL is empty
-	var url string
+// statusToString returns a formatted string of the status of the web hook
+// @arg w WebHook
+// @return string
+func statusToString(w WebHook) (status string) {
+	status = hookConfigURL(w) + " => "
+	code, message, colour := hookStatus(w)
+	if w.NoStatus {
+		return status + fmt.Sprint(colour(message))
+	}
+	status += fmt.Sprintf("%s | %s", colour(code), colour(message))
+	if showHookAge {
+		status += fmt.Sprintf(" | %s", au.Brown("updated "+formatAge(time.Since(w.UpdatedAt))+" ago"))
+	}
+	return status
+}
 
+// hookConfigURL returns the config URL of the web hook, or its name for edge
+// cases where the config URL is empty
+// @arg w WebHook
+// @return string
+func hookConfigURL(w WebHook) string {
 	if w.Config.URL != "" {
-		url = w.Config.URL
-	} else {
-		url = "No config url. Using name: " + w.Name
+		return w.Config.URL
 	}
+	return "No config url. Using name: " + w.Name
+}
 
-	status = url + " => "
-	codeString := strconv.Itoa(w.LastResponse.Code)
+// hookStatus returns the last response code and message of the web hook along
+// with the colour they are printed in
+// @arg w WebHook
+// @return string - Code, or - if not reported by the provider
+// @return string - Message
+// @return func(interface{}) aurora.Value - Colour
+func hookStatus(w WebHook) (string, string, func(interface{}) aurora.Value) {
+	if w.NoStatus {
+		return "-", "Last response not reported by provider", au.Gray
+	}
+	code := w.LastResponse.Code
+	codeString := strconv.Itoa(code)
 
 	switch {
-	case codeString[0] == '2':
-		status += fmt.Sprintf("%s | %s", Green(codeString), Green(w.LastResponse.Message))
-	case codeString[0] == '0':
-		status += fmt.Sprintf("%s | %s", Red(codeString), Red("Webhook has never been triggered"))
-	default:
-		status += fmt.Sprintf("%s | %s", Red(codeString), Red(w.LastResponse.Message))
+	case code == 0:
+		return codeString, "Webhook has never been triggered", au.Red
+	case code >= 200 && code <= 299:
+		return codeString, w.LastResponse.Message, au.Green
+	case code >= 100 && code <= 599:
+		return codeString, w.LastResponse.Message, au.Red
 	}
-	return status
+	// Not a valid HTTP status code
+	return codeString, "Unknown status", au.Red
 }
 
-// retrieveRepos retrieves repository info from a local JSON file
-// @arg filePath string - Absolute/relative file path of JSON file containing repos
-func retrieveRepos(filePath string) {
-	jsonFile, err := os.Open(filePath)
-	if err != nil {
-		printError("Issue opening repos file:", err)
+// formatAge formats a duration in whole days, or whole hours if less than a day
+// @arg age time.Duration
+// @return string
+func formatAge(age time.Duration) string {
+	if age < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(age.Hours()/24))
+}
+
+// defaultReposFileName is the name of the repos file looked for when no repo source is given
+const defaultReposFileName = "webhookit.json"
+
+// defaultReposFile finds the repos file to use when no repo source is given. It is
+// the path in WEBHOOKIT_REPOS if set, otherwise webhookit.json in the current
+// directory or in $HOME/.config/webhookit.
+// @return string
+// @return error - If no repos file can be found
+func defaultReposFile() (string, error) {
+	if envPath := os.Getenv("WEBHOOKIT_REPOS"); envPath != "" {
+		if _, err := os.Stat(envPath); err != nil {
+			return "", fmt.Errorf("WEBHOOKIT_REPOS is set but cannot be read: %v", err)
+		}
+		return envPath, nil
+	}
+
+	candidates := []string{defaultReposFileName}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".config", "webhookit", defaultReposFileName))
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("No repos given. Use -f, -r, -org, -org-repos or -team, set WEBHOOKIT_REPOS or create %s", strings.Join(candidates, " or "))
+}
+
+// repoFileFormat returns the format of a repos file, detecting it from the file
+// extension if not given. Stdin is read as text.
+// @arg filePath string
+// @arg format string - json, text or yaml, or empty to detect it
+// @return string
+func repoFileFormat(filePath, format string) string {
+	if format != "" {
+		return format
+	}
+	if filePath == "-" {
+		return "text"
+	}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".txt", ".list":
+		return "text"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "json"
+}
+
+// retrieveRepos retrieves repository info from a local file, or from stdin if filePath is "-"
+// @arg filePath string - Absolute/relative file path of file containing repos
+// @arg format string - json, text or yaml, or empty to detect it from the extension
+func retrieveRepos(filePath, format string) {
+	repoFile := os.Stdin
+	if filePath != "-" {
+		file, err := os.Open(filePath)
+		if err != nil {
+			printError("Issue opening repos file:", err)
+		}
+		defer file.Close()
+		repoFile = file
+	}
+
+	switch repoFileFormat(filePath, format) {
+	case "text":
+		retrieveReposFromList(repoFile)
+		return
+	case "yaml":
+		retrieveReposFromYAML(repoFile)
+		return
 	}
-	defer jsonFile.Close()
 
-	jsonBytes, _ := ioutil.ReadAll(jsonFile)
+	jsonBytes, _ := ioutil.ReadAll(repoFile)
 	jsonRepos := ReposContainer{}
 	json.Unmarshal(jsonBytes, &jsonRepos)
 
 	for _, value := range jsonRepos.Repos {
 		reposContainer.Repos = append(reposContainer.Repos, Repo{
-			value.Name,
+			Name: value.Name,
 		})
 	}
 }
 
-// Check API key is valid
-// @arg key string
-// @return bool
-func checkAPIKey(key string) bool {
-	return len(key) > 0
+// retrieveReposFromList retrieves repository names from a newline delimited list,
+// ignoring blank lines and lines starting with #
+// @arg reader io.Reader
+func retrieveReposFromList(reader io.Reader) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		reposContainer.Repos = append(reposContainer.Repos, Repo{
+			Name: line,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		printError("Issue reading repos list:", err)
+	}
 }
 
-// makeAPIRequest makes an API request to GitHub, passing any received data into output
-// @arg requestURL string - API request url
-// @arg httpType string - HTTP method to use
-// @arg output interface{} - Object to output JSON response to
-// @return error
-func makeAPIRequest(requestURL, httpType string, output interface{}) error {
-	// Build request
-	request, err := http.NewRequest(httpType, requestURL, nil)
-	if err != nil {
-		return err
+// retrieveReposFromYAML retrieves repository names from the items of a YAML list,
+// either plain names or maps with a name key, optionally under a repos key.
+// Other YAML is ignored.
+// @arg reader io.Reader
+func retrieveReposFromYAML(reader io.Reader) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "-") {
+			continue
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(line, "-"))
+		item = strings.TrimSpace(strings.TrimPrefix(item, "name:"))
+		item = strings.Trim(item, "\"'")
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		reposContainer.Repos = append(reposContainer.Repos, Repo{
+			Name: item,
+		})
 	}
+	if err := scanner.Err(); err != nil {
+		printError("Issue reading repos list:", err)
+	}
+}
 
-	// Add authorisation token to header
-	request.Header.Add("Authorization", "token "+apiKey)
+// deduplicateRepos removes repos listed more than once, keeping the first of each
+// @arg repos []Repo
+// @return []Repo
+// @return int - Number of duplicates removed
+func deduplicateRepos(repos []Repo) ([]Repo, int) {
+	seen := make(map[Repo]bool, len(repos))
+	var unique []Repo
+	for _, repo := range repos {
+		if seen[repo] {
+			continue
+		}
+		seen[repo] = true
+		unique = append(unique, repo)
+	}
+	return unique, len(repos) - len(unique)
+}
 
-	// Execute request
-	response, err := client.Do(request)
-	if err != nil {
-		return err
+// excludeRepos removes repos whose name matches any of the glob patterns e.g. org/internal-*
+// @arg repos []Repo
+// @arg patterns []string - Patterns using the syntax of path.Match
+// @return []Repo
+// @return int - Number of repos removed
+func excludeRepos(repos []Repo, patterns []string) ([]Repo, int) {
+	var kept []Repo
+	for _, repo := range repos {
+		excluded := false
+		for _, pattern := range patterns {
+			// Patterns are validated before repos are retrieved
+			if matched, _ := path.Match(pattern, repo.Name); matched {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, repo)
+		}
 	}
-	defer response.Body.Close()
+	return kept, len(repos) - len(kept)
+}
 
-	if response.StatusCode != 200 && response.StatusCode != 204 {
-		return fmt.Errorf("%s %d %s", "HTTP Status Code", response.StatusCode, "returned")
+// includeRepos keeps only repos whose name matches any of the glob patterns.
+// Patterns without a / match the repo name without its owner e.g. service-*.
+// @arg repos []Repo
+// @arg patterns []string - Patterns using the syntax of path.Match
+// @return []Repo
+// @return int - Number of repos removed
+func includeRepos(repos []Repo, patterns []string) ([]Repo, int) {
+	var kept []Repo
+	for _, repo := range repos {
+		name := repo.Name[strings.LastIndex(repo.Name, "/")+1:]
+		for _, pattern := range patterns {
+			target := name
+			if strings.Contains(pattern, "/") {
+				target = repo.Name
+			}
+			// Patterns are validated before repos are retrieved
+			if matched, _ := path.Match(pattern, target); matched {
+				kept = append(kept, repo)
+				break
+			}
+		}
 	}
-	return json.NewDecoder(response.Body).Decode(output)
+	return kept, len(repos) - len(kept)
 }
 
-// Retrieves webhooks for a specified repository
-// @arg repoName string
-// @return WebHooks Any webhooks found
+// validateRepoPatterns checks each -include or -exclude pattern is a valid glob
+// @arg patterns []string
 // @return error
-func getWebHooks(repoName string) (WebHooks, error) {
-	var webHooks WebHooks
+func validateRepoPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%s: %v", pattern, err)
+		}
+	}
+	return nil
+}
 
-	// Build API request URL
-	requestURL := "https://api.github.com/repos/" + repoName + "/hooks"
-	httpType := "GET"
+// retrieveOrgRepos retrieves every repository of an organization from the GitHub API
+// @arg ctx context.Context - Cancels requests when done
+// @arg org string
+// @arg includeArchived bool - Whether archived repositories are included
+func retrieveOrgRepos(ctx context.Context, org string, includeArchived bool) {
+	requestURL := apiClient.APIURL() + "/orgs/" + org + "/repos?per_page=" + strconv.Itoa(webhookit.ListPageSize)
+	if err := appendRepoPages(ctx, requestURL, includeArchived); err != nil {
+		printError("Issue retrieving repos of organization "+org+":", err)
+	}
+}
 
-	// Execute request and check for errors
-	err := makeAPIRequest(requestURL, httpType, &webHooks.Hooks)
-	if err != nil {
-		return WebHooks{}, fmt.Errorf("API Request Error : %s encountered error : %s", repoName, err)
+// teamRegex matches a team given as the organization and slug of the team e.g. org/team-slug
+var teamRegex = regexp.MustCompile(`^[^/\s]+/[^/\s]+$`)
+
+// retrieveTeamRepos retrieves every repository a team has access to from the GitHub API
+// @arg ctx context.Context - Cancels requests when done
+// @arg team string - Organization and slug of the team e.g. org/team-slug
+// @arg includeArchived bool - Whether archived repositories are included
+func retrieveTeamRepos(ctx context.Context, team string, includeArchived bool) {
+	// Validated by main
+	parts := strings.SplitN(team, "/", 2)
+	requestURL := apiClient.APIURL() + "/orgs/" + parts[0] + "/teams/" + parts[1] + "/repos?per_page=" + strconv.Itoa(webhookit.ListPageSize)
+	if err := appendRepoPages(ctx, requestURL, includeArchived); err != nil {
+		printError("Issue retrieving repos of team "+team+":", err)
 	}
-	return webHooks, nil
 }
 
-// Backups webhooks to a local JSON file
-func backupWebHooks(filepath string, webHooks WebHooks) error {
-	webHooksJSON, err := json.Marshal(webHooks)
+// appendRepoPages adds every repository listed by a paginated GitHub API request to reposContainer
+// @arg ctx context.Context - Cancels requests when done
+// @arg requestURL string - API request url of the first page of repositories
+// @arg includeArchived bool - Whether archived repositories are included
+// @return error
+func appendRepoPages(ctx context.Context, requestURL string, includeArchived bool) error {
+	return apiClient.Paginate(ctx, requestURL, func(body io.Reader) error {
+		var page []struct {
+			FullName string `json:"full_name"`
+			Archived bool   `json:"archived"`
+			Disabled bool   `json:"disabled"`
+		}
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return err
+		}
+		for _, value := range page {
+			if value.Archived && !includeArchived {
+				continue
+			}
+			reposContainer.Repos = append(reposContainer.Repos, Repo{
+				Name:     value.FullName,
+				Disabled: value.Disabled,
+			})
+		}
+		return nil
+	})
+}
+
+// readTokenFile reads an API key from the first line of a file
+// @arg filePath string
+// @return string
+// @return error
+func readTokenFile(filePath string) (string, error) {
+	contents, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return ioutil.WriteFile(filepath, webHooksJSON, 0644)
+	firstLine := strings.SplitN(string(contents), "\n", 2)[0]
+	return strings.TrimSpace(firstLine), nil
 }
 
-// Executes the backup functionality
+// configureProxy routes requests through a proxy URL, or through the proxy of the
+// HTTP_PROXY/HTTPS_PROXY environment variables when proxyURL is empty
+// @arg proxyURL string
 // @return error
-func executeBackup(filepath string, webHooks WebHooks) error {
-	if filepath == "" {
+func configureProxy(proxyURL string) error {
+	if proxyURL == "" {
+		transport.Proxy = http.ProxyFromEnvironment
 		return nil
 	}
-	err := backupWebHooks(filepath, webHooks)
+	parsedURL, err := url.Parse(proxyURL)
 	if err != nil {
-		return errors.New(fmt.Sprint(Red("Error backing up webhooks:"), Red(err)))
+		return err
+	}
+	if parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return fmt.Errorf("proxy url %q must include a scheme and host", proxyURL)
 	}
-	fmt.Println(fmt.Sprintf("%s %s\n", Magenta("Successfully backed up webhooks to"), Brown(filepath)))
+	transport.Proxy = http.ProxyURL(parsedURL)
 	return nil
 }
 
-// Executes API requests to GitHub based on the options passed in
-// @arg backupFlag string
+// configureTLS trusts the CA certificates of a PEM file in addition to the system
+// cert pool, and optionally disables verification of server certificates
+// @arg caCertPath string - Empty to use only the system cert pool
+// @arg insecureSkipVerify bool
 // @return error
-func executeCheck(backupFlag string) error {
-	// Print title
-	title := fmt.Sprintf("%s\n%s\n%s\n", Bold(Gray("* * * * * * * * * * * * * * * * * * * *")), Bold(Brown("             C H E C K")), Bold(Gray("* * * * * * * * * * * * * * * * * * * *")))
-	fmt.Println(title)
-
-	fmt.Println(Bold(Gray("Checking GitHub repo(s) for validity of webhooks...\n")))
-
-	// Array containing indexes of duplicate hooks
-	allWebHooks := WebHooks{}
-	// Total output of hooks
-	var totalOutput string
+func configureTLS(caCertPath string, insecureSkipVerify bool) error {
+	if caCertPath == "" && !insecureSkipVerify {
+		return nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
 
-	// For each repo...
-	for _, repo := range reposContainer.Repos {
-		// Get web hooks
-		webHooks, err := getWebHooks(repo.Name)
+	if caCertPath != "" {
+		pemBytes, err := ioutil.ReadFile(caCertPath)
 		if err != nil {
-			fmt.Printf("%s %s\n\n", Red("Failed to retrieve web hooks:"), Red(err))
-			continue
+			return err
 		}
-
-		// Add webHooks to allWebHooks for backup
-		if backupFlag != "" {
-			allWebHooks.Hooks = append(allWebHooks.Hooks, webHooks.Hooks...)
+		certPool, err := x509.SystemCertPool()
+		if err != nil || certPool == nil {
+			certPool = x509.NewCertPool()
 		}
-
-		// Convert WebHooks to map of HookWrappers
-		hooksMap := make(map[string]*HookWrapper, len(webHooks.Hooks))
-		for _, hook := range webHooks.Hooks {
-			hooksMap[hook.URL] = &HookWrapper{
-				Hook: hook,
-				Code: strings.ToUpper(strconv.Itoa(hook.LastResponse.Code)),
-			}
+		if !certPool.AppendCertsFromPEM(pemBytes) {
+			return fmt.Errorf("no PEM certificates found in %s", caCertPath)
 		}
-		// For each hook...
-		for index := range hooksMap {
-			currentItem := hooksMap[index].Hook.URL
+		tlsConfig.RootCAs = certPool
+	}
 
-			for dIndex := range hooksMap {
-				iterateItem := hooksMap[dIndex].Hook.URL
-				// Skip the same hook or an item already marked as duplicate
-				if currentItem == iterateItem || hooksMap[iterateItem].Duplicate == true {
-					continue
-				}
+	transport.TLSClientConfig = tlsConfig
+	return nil
+}
 
-				// Check if hook is a duplicate
-				if hooksMap[currentItem].Hook.Config.URL != "" && (hooksMap[currentItem].Hook.Config.URL == hooksMap[iterateItem].Hook.Config.URL) {
-					// Mark hooks as duplicate
-					hooksMap[currentItem].Duplicate = true
-					hooksMap[iterateItem].Duplicate = true
-				}
+// newAPIClient creates the client making API requests with the request options
+// @arg provider webhookit.Provider
+// @return *webhookit.Client
+func newAPIClient(provider webhookit.Provider) *webhookit.Client {
+	apiClient := &webhookit.Client{
+		HTTPClient:     client,
+		Provider:       provider,
+		Token:          apiKey,
+		APIVersion:     apiVersion,
+		Headers:        http.Header(customHeaders),
+		MaxRetries:     maxRetries,
+		RequestDelay:   requestDelay,
+		RepoTimeout:    repoTimeout,
+		IgnoreNotFound: ignoreNotFound,
+		Verbosity:      verbosity,
+		Log:            os.Stderr,
+		OnResponse: func(response *http.Response) {
+			apiStats.record(response)
+			if response != nil {
+				warnTokenExpiry(response)
 			}
-		}
-
-		// Print name of repo
-		printName := fmt.Sprintf("%s\n\n", Bold(Magenta(repo.Name)))
-		totalOutput += printName
-
-		// Append each hook string ot totalOutput
-		for _, hook := range hooksMap {
-			totalOutput += hook.ToString() + "\n"
-		}
-
-		// Newline to space out each repo
-		totalOutput += "\n"
+		},
+		OnRateLimit: func(reset time.Time) {
+			fmt.Fprintln(infoOutput, au.Brown(fmt.Sprintf("Rate limited, sleeping until %s", reset.Format("15:04"))))
+		},
 	}
-
-	// Execution of backup. Backup will only occur if a non-empty backupFlag is present
-	if err := executeBackup(backupFlag, allWebHooks); err != nil {
-		printError("Backup failed:", err)
+	if cacheDir != "" {
+		apiClient.Cache = dirCache{}
 	}
+	return apiClient
+}
 
-	// Print totalOutput
-	fmt.Println(totalOutput)
-
-	fmt.Println(Green("Check complete."))
-
-	return nil
+// Check API key is valid
+// @arg key string
+// @return bool
+func checkAPIKey(key string) bool {
+	return len(key) > 0
 }
 
-// Validates that the typesFlag passed in is a CSV list meeting the criteria
-// of status codes in the form 3XX to 5XX.
-// @arg typesFlag string - CSV string of types
-// @return []string - Contains each type as a string
-// @return error
-func validateTypesFlag(typesFlag string) ([]string, error) {
-	if strings.ToLower(typesFlag) == "none" {
-		return []string{"N/A"}, nil
+// logVerbose writes a message to stderr if verbosity is at least level
+// @arg level int
+// @arg format string
+// @arg args ...interface{}
+func logVerbose(level int, format string, args ...interface{}) {
+	if verbosity >= level {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
 	}
+}
 
-	var failedTypes []string
-	r, _ := regexp.Compile("\\d{3}|\\d[xX][xX]|\\d\\d[xX]")
-	types := strings.Split(typesFlag, ",")
+// RepoSkippedError is returned when the webhooks of a repo are not retrieved
+// because the repo is archived or disabled
+type RepoSkippedError = webhookit.RepoSkippedError
 
-	for index, aType := range types {
-		if !r.MatchString(aType) {
-			failedTypes = append(failedTypes, aType)
-		}
-		types[index] = strings.ToUpper(aType)
-	}
+// ignoreNotFound skips repos whose webhooks respond with 404 without printing an
+// error for each, listing them once at the end instead
+var ignoreNotFound bool
+
+// notFoundRepos are the repos skipped by ignoreNotFound since they were last printed
+var notFoundRepos = make(map[string]bool)
 
-	if len(failedTypes) > 0 {
-		return types, fmt.Errorf("Invalid types found: %s", failedTypes)
+// printNotFoundRepos prints the repos skipped by ignoreNotFound, if any, as a single list
+func printNotFoundRepos() {
+	if len(notFoundRepos) == 0 {
+		return
 	}
-	return types, nil
+	names := make([]string, 0, len(notFoundRepos))
+	for name := range notFoundRepos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(infoOutput, "%s\n%s\n", au.Brown(fmt.Sprintf("Skipped %d repo(s) that were not found or could not be accessed:", len(names))), au.Gray("  "+strings.Join(names, "\n  ")))
+	notFoundRepos = make(map[string]bool)
 }
 
-// Takes a string array and replaces any instances of 'X' in each string with '\\d' then joins
-// each string using '|'.
-// @arg types []string
-// @return string - Regex string
-func convertTypesToRegex(types []string) string {
-	var newTypes []string
-
-	for _, aType := range types {
-		newTypes = append(newTypes, strings.Replace(aType, "X", "\\d", -1))
+// repoErrorMessage formats an error retrieving the webhooks of a repo, with a
+// distinct notice for repos skipped as archived or disabled
+// @arg err error
+// @return string
+func repoErrorMessage(err error) string {
+	var skipped *RepoSkippedError
+	if errors.As(err, &skipped) {
+		// Listed by printNotFoundRepos instead
+		if skipped.Reason == webhookit.RepoNotFound {
+			return ""
+		}
+		return fmt.Sprintf("%s\n\n", au.Brown(err))
 	}
-	return strings.Join(newTypes, "|")
+	return fmt.Sprintf("%s %s\n\n", au.Red("Failed to retrieve web hooks:"), au.Red(err))
 }
 
-// Generates random pass phrase
-// @arg length int - Length of passphrase to generate
-// @return string - The passphrase
-func generatePassPhrase(length int) string {
-	bytes := make([]byte, length)
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	for i := 0; i < length; i++ {
-		bytes[i] = byte(65 + rand.Intn(25))
+// changeWebHooksOf makes an API request changing the webhooks of a repo or
+// organization. Once it succeeds the cached list of its webhooks is invalidated
+// so a later cached run does not show them as they were.
+// @arg ctx context.Context - Cancels requests when done
+// @arg repo Repo - Owner of the webhooks changed
+// @arg requestURL string
+// @arg method string - e.g. POST or PATCH
+// @arg input interface{} - Encoded as the JSON body of the request
+// @return error
+func changeWebHooksOf(ctx context.Context, repo Repo, requestURL, method string, input interface{}) error {
+	if err := apiClient.Request(ctx, requestURL, method, input, nil); err != nil {
+		return err
 	}
-	return string(bytes)
+	apiClient.InvalidateWebHooks(repo)
+	return nil
 }
 
-// Checks an int array for an instance of a supplied int
-// @arg array []int
-// @arg input int
-// @return bool
-func contains(array []int, input int) bool {
-	for _, item := range array {
-		if item == input {
-			return true
+// Retrieves webhooks for a specified repository or organization, noting repos
+// skipped by ignoreNotFound so they are listed once at the end
+// @arg ctx context.Context - Cancels requests when done
+// @arg repo Repo
+// @return WebHooks Any webhooks found
+// @return error
+func getWebHooks(ctx context.Context, repo Repo) (WebHooks, error) {
+	hooks, err := apiClient.ListWebHooks(ctx, repo)
+	if err != nil {
+		var skipped *RepoSkippedError
+		if errors.As(err, &skipped) && skipped.Reason == webhookit.RepoNotFound {
+			notFoundRepos[repo.Name] = true
 		}
+		return WebHooks{}, err
 	}
-	return false
+	return WebHooks{Hooks: hooks}, nil
 }
 
-// Appends a string to a string array if the input string is not already present
-// @arg array []string
-// @arg input string
-// @return []string
-func uniqueAppend(array []string, input string) []string {
-	for _, item := range array {
-		if item == input {
-			return array
-		}
+// backupSchemaVersion is the schema version of the backup format written by
+// backupWebHooks and read by readBackup. Version 1 backups had no version field
+// and no owning repo on each hook.
+const backupSchemaVersion = 2
+
+// Backup is the format of a backup file
+type Backup struct {
+	SchemaVersion int `json:"schema_version"`
+	// LegacyVersion is the schema version as written by earlier releases
+	LegacyVersion int       `json:"version,omitempty"`
+	Hooks         []WebHook `json:"Hooks"`
+}
+
+// Backups webhooks to a local JSON file
+func backupWebHooks(filePath string, webHooks WebHooks) error {
+	webHooksJSON, err := json.Marshal(Backup{
+		SchemaVersion: backupSchemaVersion,
+		Hooks:         webHooks.Hooks,
+	})
+	if err != nil {
+		return err
 	}
-	return append(array, input)
+	return writeFileAtomic(filePath, webHooksJSON, 0644)
 }
 
-// Destroys a webhook using a supplied API URL
-// @arg requestURL string
+// writeFileAtomic writes data to a temporary file in the same directory as filePath
+// then renames it into place, so filePath is never left partially written
+// @arg filePath string
+// @arg data []byte
+// @arg perm os.FileMode
 // @return error
-func destroyWebHook(requestURL string) error {
-	// Build request
-	request, err := http.NewRequest("DELETE", requestURL, nil)
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-")
 	if err != nil {
 		return err
 	}
+	tempPath := tempFile.Name()
 
-	// Add authorisation token to header
-	request.Header.Add("Authorization", "token "+apiKey)
+	// Remove the temporary file if it is not renamed into place
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tempPath)
+		}
+	}()
 
-	// Execute request
-	response, err := client.Do(request)
-	if err != nil {
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode == 204 {
-		return nil
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, filePath); err != nil {
+		return err
 	}
-	return errors.New("Encountered error deleting " + requestURL)
+	renamed = true
+	return nil
 }
 
-// Destroys multiple webhooks using an array of API URLs
-// @arg webHookURLs []string
+// Executes the backup functionality
 // @return error
-func destroyWebHooks(webHookURLs []string) error {
-	errorString := ""
-	for _, url := range webHookURLs {
-		err := destroyWebHook(url)
-		if err != nil {
-			errorString += fmt.Sprintf("- %s %s : %s", Red("Error deleting web hook"), url, Red(err))
-		}
-		if errorString != "" {
-			return errors.New(errorString)
-		}
+func executeBackup(filepath string, webHooks WebHooks) error {
+	if filepath == "" {
+		return nil
 	}
+	err := backupWebHooks(filepath, webHooks)
+	if err != nil {
+		return errors.New(fmt.Sprint(au.Red("Error backing up webhooks:"), au.Red(err)))
+	}
+	fmt.Fprintln(infoOutput, fmt.Sprintf("%s %s\n", au.Magenta("Successfully backed up webhooks to"), au.Brown(filepath)))
 	return nil
 }
 
-// Compare two string arrays
-// @return bool True if arrays are different, false if not or an error occurs
-func compareStringArrays(arrayOne, arrayTwo []string) bool {
-	// Check for nil arrays
-	if (arrayOne == nil || arrayTwo == nil) || (len(arrayOne) != len(arrayTwo)) {
-		return true
-	}
+// CheckError is returned by executeCheck when repos could not be checked or the
+// backup failed. Results of the remaining repos are still printed.
+type CheckError struct {
+	// FailedRepos maps each repo that could not be checked to its error
+	FailedRepos map[string]error
+	BackupErr   error
+	// Interrupted is the error of the context if it was done before every repo was checked
+	Interrupted error
+}
 
-	for i := range arrayOne {
-		if arrayOne[i] != arrayTwo[i] {
+func (e *CheckError) Error() string {
+	var messages []string
+	if len(e.FailedRepos) > 0 {
+		names := make([]string, 0, len(e.FailedRepos))
+		for name := range e.FailedRepos {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		messages = append(messages, fmt.Sprintf("%d repo(s) could not be checked: %s", len(names), strings.Join(names, ", ")))
+	}
+	if e.BackupErr != nil {
+		messages = append(messages, "backup failed")
+	}
+	if e.Interrupted != nil {
+		messages = append(messages, "results are partial: "+e.Interrupted.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// CheckSummary tallies the hooks found by a check
+type CheckSummary = webhookit.Summary
+
+// writeCounts writes the tallies alone in the output format: a single line of
+// name=value pairs for text, an object for json and ndjson or a header and row for csv
+// @arg writer io.Writer
+// @arg s CheckSummary
+// @arg format string
+// @return error
+func writeSummaryCounts(writer io.Writer, s CheckSummary, format string) error {
+	names := []string{"repos", "failed_repos", "hooks", "healthy", "broken", "never_triggered", "duplicates", "duplicate_groups", "no_secret", "events_mismatch"}
+	values := []int{s.Repos, s.FailedRepos, s.Hooks, s.Healthy, s.Broken, s.NeverTriggered, s.Duplicates, s.DuplicateGroups, s.NoSecret, s.EventsMismatch}
+
+	switch format {
+	case "json", "ndjson":
+		return json.NewEncoder(writer).Encode(s)
+	case "csv":
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = strconv.Itoa(value)
+		}
+		csvWriter := csv.NewWriter(writer)
+		csvWriter.Write(names)
+		csvWriter.Write(record)
+		csvWriter.Flush()
+		return csvWriter.Error()
+	default:
+		pairs := make([]string, len(values))
+		for i, value := range values {
+			pairs[i] = fmt.Sprintf("%s=%d", names[i], value)
+		}
+		_, err := fmt.Fprintln(writer, strings.Join(pairs, " "))
+		return err
+	}
+}
+
+// summaryToString returns a summary as a formatted footer
+// @arg s CheckSummary
+// @return string
+func summaryToString(s CheckSummary) string {
+	broken := au.Bold(au.Green(s.Broken))
+	if s.Broken > 0 {
+		broken = au.Bold(au.Red(s.Broken))
+	}
+	output := fmt.Sprintf("%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	output += fmt.Sprintf("%s %d\n", au.Gray("Repos scanned:   "), au.Bold(s.Repos))
+	if s.FailedRepos > 0 {
+		output += fmt.Sprintf("%s %d\n", au.Gray("Repos failed:    "), au.Bold(au.Red(s.FailedRepos)))
+	}
+	output += fmt.Sprintf("%s %d\n", au.Gray("Total hooks:     "), au.Bold(s.Hooks))
+	output += fmt.Sprintf("%s %d\n", au.Gray("Healthy (2XX):   "), au.Bold(au.Green(s.Healthy)))
+	output += fmt.Sprintf("%s %d\n", au.Gray("Broken:          "), broken)
+	output += fmt.Sprintf("%s %d\n", au.Gray("Never triggered: "), au.Bold(s.NeverTriggered))
+	output += fmt.Sprintf("%s %d\n", au.Gray("Duplicates:      "), au.Bold(au.Cyan(s.Duplicates)))
+	if s.DuplicateGroups > 0 {
+		output += fmt.Sprintf("%s %d\n", au.Gray("Duplicate groups:"), au.Bold(au.Cyan(s.DuplicateGroups)))
+	}
+	if s.NoSecret > 0 {
+		output += fmt.Sprintf("%s %d\n", au.Gray("No secret:       "), au.Bold(au.Red(s.NoSecret)))
+	}
+	if s.EventsMismatch > 0 {
+		output += fmt.Sprintf("%s %d\n", au.Gray("Events mismatch: "), au.Bold(au.Magenta(s.EventsMismatch)))
+	}
+	output += fmt.Sprintf("%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	return output
+}
+
+// CheckOptions holds the options of the check process
+type CheckOptions struct {
+	Backup string
+	// Output is the output format, either text, json, ndjson or csv
+	Output string
+	// FailOnBroken returns errBrokenHooks if any broken hooks are found
+	FailOnBroken bool
+	// Quiet omits healthy hooks, and repos with only healthy hooks, from the results
+	Quiet bool
+	// DupOnly omits hooks that are not duplicates, and repos without duplicates, from the results
+	DupOnly bool
+	// Sort is the order of the hooks of each repo, one of hookSortOrders
+	Sort string
+	// ExpectEvents flags hooks whose events differ from the sorted set. Nil disables the comparison.
+	ExpectEvents []string
+	// Count prints only the tallies of the summary instead of any results
+	Count bool
+	// OutFile is the path of a file results are written to instead of stdout, with
+	// ANSI codes stripped from text results. Only the summary is printed. Empty uses stdout.
+	OutFile string
+	// Stream prints the results of each repo as soon as it is checked instead
+	// of once all repos are checked. Not supported with json output.
+	Stream bool
+	// StatusOutput receives a CheckStatus once the check is complete. Nil disables it.
+	StatusOutput io.Writer
+	// ResultsOutput receives the results instead of stdout when OutFile is empty. Nil uses stdout.
+	ResultsOutput io.Writer
+	// RepoSummaries is filled with the tally of each repo checked when not nil
+	RepoSummaries map[string]CheckSummary
+}
+
+// CheckStatus is the machine-readable outcome of a check written to -status-fd
+type CheckStatus struct {
+	Success  bool `json:"success"`
+	ExitCode int  `json:"exit_code"`
+	CheckSummary
+}
+
+// Executes API requests to GitHub based on the options passed in
+// @arg ctx context.Context - Cancels requests when done
+// @arg options CheckOptions
+// @arg filter HookFilter
+// @return error - *CheckError if any repo could not be checked or the backup failed,
+// otherwise errBrokenHooks if broken hooks are found and options.FailOnBroken is set
+func executeCheck(ctx context.Context, options CheckOptions, filter HookFilter) error {
+	// Machine-readable formats print only results to stdout
+	machineOutput := options.Output != "text" || options.Count
+	if !machineOutput {
+		// Print title
+		title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("             C H E C K")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+		fmt.Println(title)
+
+		fmt.Println(au.Bold(au.Gray("Checking GitHub repo(s) for validity of webhooks...\n")))
+	}
+
+	// Array containing indexes of duplicate hooks
+	allWebHooks := WebHooks{}
+	// Total output of hooks
+	var totalOutput string
+	// Results of each hook for machine-readable output
+	results := []CheckResult{}
+	// Tally of hooks found
+	summary := CheckSummary{}
+	// Failures to report once all repos are checked
+	checkErr := &CheckError{FailedRepos: make(map[string]error)}
+
+	// Results are written to stdout unless an out file is given
+	var resultsOutput io.Writer = os.Stdout
+	if options.ResultsOutput != nil {
+		resultsOutput = options.ResultsOutput
+	}
+	if options.OutFile != "" {
+		outFile, err := os.Create(options.OutFile)
+		if err != nil {
+			printError("Issue creating out file:", err)
+		}
+		defer outFile.Close()
+		resultsOutput = outFile
+	}
+	// writeText writes text results, without colours if they go to a file
+	writeText := func(text string) {
+		if options.OutFile != "" {
+			text = stripANSI(text)
+		}
+		fmt.Fprint(resultsOutput, text)
+	}
+
+	// NDJSON results are written a line per hook as soon as it is checked
+	var ndjsonEncoder *json.Encoder
+	if options.Output == "ndjson" && !options.Count {
+		ndjsonEncoder = json.NewEncoder(resultsOutput)
+	}
+
+	// Streamed CSV rows share one writer so the header is written once
+	var csvWriter *csv.Writer
+	if options.Stream && options.Output == "csv" {
+		csvWriter = csv.NewWriter(resultsOutput)
+		csvWriter.Write(checkResultsCSVHeader)
+		csvWriter.Flush()
+	}
+
+	// For each repo...
+	for index, repo := range reposContainer.Repos {
+		if deadlineReached(ctx, index, len(reposContainer.Repos)) {
+			checkErr.Interrupted = ctx.Err()
+			break
+		}
+		printProgress(index, len(reposContainer.Repos), repo.Name)
+
+		// Get web hooks
+		webHooks, err := getWebHooks(ctx, repo)
+		if err != nil {
+			clearProgress()
+			fmt.Fprint(infoOutput, repoErrorMessage(err))
+			// Archived and disabled repos are expected to be skipped
+			var skipped *RepoSkippedError
+			if errors.As(err, &skipped) {
+				continue
+			}
+			summary.FailedRepos++
+			checkErr.FailedRepos[repo.Name] = err
+			if options.RepoSummaries != nil {
+				options.RepoSummaries[repo.Name] = CheckSummary{FailedRepos: 1}
+			}
+			continue
+		}
+		summary.Repos++
+		repoSummary := CheckSummary{Repos: 1}
+
+		// Add webHooks to allWebHooks for backup
+		if options.Backup != "" {
+			allWebHooks.Hooks = append(allWebHooks.Hooks, webHooks.Hooks...)
+		}
+
+		// Only consider hooks passing the filter
+		webHooks.Hooks = webhookit.FilterWebHooks(webHooks.Hooks, filter)
+
+		// Convert WebHooks to map of HookWrappers
+		hooksMap := wrapWebHooks(webHooks)
+		// Mark hooks sharing a config URL as duplicates
+		duplicateGroups := len(groupDuplicates(hooksMap))
+		summary.DuplicateGroups += duplicateGroups
+		repoSummary.DuplicateGroups += duplicateGroups
+
+		// Print name of repo. Compact output names the repo on every line instead.
+		repoOutput := fmt.Sprintf("%s\n\n", au.Bold(au.Magenta(repo.Name)))
+		if compactOutput {
+			repoOutput = ""
+		}
+		repoResults := []CheckResult{}
+
+		// Append each hook string to repoOutput
+		for _, hook := range sortHookWrappers(hooksMap, options.Sort) {
+			// Events are sorted before comparing so their order is ignored
+			if options.ExpectEvents != nil {
+				hook.EventsMismatch = compareStringArrays(sortedCopy(hook.Hook.Events), options.ExpectEvents)
+			}
+			summary.Add(hook.Hook, hook.Duplicate, hook.EventsMismatch)
+			repoSummary.Add(hook.Hook, hook.Duplicate, hook.EventsMismatch)
+			if (options.Quiet && !hook.Hook.HasProblem() && !hook.EventsMismatch) || (options.DupOnly && !hook.Duplicate) {
+				continue
+			}
+			result := CheckResult{
+				Repo:           repo.Name,
+				HookID:         hook.Hook.ID,
+				HookURL:        hook.Hook.URL,
+				ConfigURL:      hook.Hook.Config.URL,
+				Code:           hook.Hook.LastResponse.Code,
+				Message:        hook.Hook.LastResponse.Message,
+				Active:         hook.Hook.Active,
+				Duplicate:      hook.Duplicate,
+				Secret:         hook.Hook.HasSecret(),
+				EventsMismatch: hook.EventsMismatch,
+			}
+			logCheckResult(result, hook.Hook.HasProblem())
+			if ndjsonEncoder != nil {
+				clearProgress()
+				if err := ndjsonEncoder.Encode(result); err != nil {
+					return err
+				}
+				continue
+			}
+			if compactOutput {
+				repoOutput += compactHook(repo.Name, hook)
+			} else {
+				repoOutput += renderHook(repo.Name, hook) + "\n"
+			}
+			repoResults = append(repoResults, result)
+		}
+
+		if options.RepoSummaries != nil {
+			options.RepoSummaries[repo.Name] = repoSummary
+		}
+
+		// NDJSON results have already been written so nothing is held in memory
+		if ndjsonEncoder != nil {
+			continue
+		}
+
+		// Newline to space out each repo
+		if !compactOutput {
+			repoOutput += "\n"
+		}
+
+		if (options.Quiet || options.DupOnly) && len(repoResults) == 0 {
+			continue
+		}
+
+		if !options.Stream {
+			totalOutput += repoOutput
+			results = append(results, repoResults...)
+			continue
+		}
+
+		// Print the results of the repo straight away
+		clearProgress()
+		if csvWriter != nil {
+			for _, result := range repoResults {
+				csvWriter.Write(result.csvRecord())
+			}
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		} else if compactOutput {
+			// Columns are aligned within each repo as later repos are not known yet
+			writeText(alignColumns(repoOutput))
+		} else {
+			writeText(repoOutput)
+		}
+	}
+
+	clearProgress()
+
+	// Execution of backup. Backup will only occur if a non-empty options.Backup is present
+	if err := executeBackup(options.Backup, allWebHooks); err != nil {
+		fmt.Fprintln(infoOutput, err)
+		checkErr.BackupErr = err
+	}
+
+	switch {
+	case options.Count:
+		if err := writeSummaryCounts(resultsOutput, summary, options.Output); err != nil {
+			return err
+		}
+	case options.Stream && options.Output == "csv", options.Output == "ndjson":
+		// Results have already been printed
+	case options.Output == "json":
+		encoder := json.NewEncoder(resultsOutput)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	case options.Output == "csv":
+		if err := writeCheckResultsCSV(resultsOutput, results); err != nil {
+			return err
+		}
+	case compactOutput:
+		writeText(alignColumns(totalOutput) + "\n")
+	default:
+		// Print totalOutput
+		writeText(totalOutput + "\n")
+	}
+
+	// The summary is shown on the terminal whenever stdout is not used for machine-readable results
+	if !machineOutput || options.OutFile != "" {
+		fmt.Println(summaryToString(summary))
+		fmt.Println(au.Gray(apiStats.ToString()))
+		if options.OutFile != "" {
+			fmt.Printf("%s %s\n", au.Magenta("Results written to"), au.Brown(options.OutFile))
+		}
+		fmt.Println(au.Green("Check complete."))
+	}
+
+	var result error
+	status := CheckStatus{Success: true, CheckSummary: summary}
+	switch {
+	case len(checkErr.FailedRepos) > 0 || checkErr.BackupErr != nil || checkErr.Interrupted != nil:
+		result = checkErr
+		status.Success, status.ExitCode = false, 1
+	case options.FailOnBroken && summary.Broken > 0:
+		result = errBrokenHooks
+		status.Success, status.ExitCode = false, exitBrokenHooks
+	}
+	if options.StatusOutput != nil {
+		if err := json.NewEncoder(options.StatusOutput).Encode(status); err != nil {
+			fmt.Fprintln(infoOutput, au.Red(fmt.Sprint("Issue writing status: ", err)))
+		}
+	}
+	return result
+}
+
+// Generates random pass phrase of the letters A to Z using crypto/rand so it cannot be predicted
+// @arg length int - Length of passphrase to generate
+// @return string - The passphrase
+func generatePassPhrase(length int) string {
+	bytes := make([]byte, length)
+	letters := big.NewInt(26)
+
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, letters)
+		if err != nil {
+			printError("Issue generating passphrase:", err)
+		}
+		bytes[i] = byte('A' + n.Int64())
+	}
+	return string(bytes)
+}
+
+// Checks an int array for an instance of a supplied int
+// @arg array []int
+// @arg input int
+// @return bool
+func contains(array []int, input int) bool {
+	for _, item := range array {
+		if item == input {
 			return true
 		}
 	}
 	return false
 }
 
-// Output the diff of two webhooks and allow user to select one to return
-// Marks hooks if user chooses to destroy them
-// @arg hookOne WebHook
-// @arg hookTwo WebHook
-// @return error
-func duplicateDiff(HookWrappers ...*HookWrapper) error {
-	// Must have at least two hooks to compare
-	if len(HookWrappers) < 1 {
-		return nil
+// Appends a string to a string array if the input string is not already present
+// @arg array []string
+// @arg input string
+// @return []string
+func uniqueAppend(array []string, input string) []string {
+	for _, item := range array {
+		if item == input {
+			return array
+		}
 	}
+	return append(array, input)
+}
 
-	fmt.Println(Bold(Magenta("\n* * * * * * * * * * *\n   DUPLICATE FOUND\n* * * * * * * * * * *\n")))
+// InterruptedError is returned when a signal stops webhooks being changed part way
+type InterruptedError struct {
+	Signal os.Signal
+	// Changed is the number of webhooks changed before the signal was handled
+	Changed int
+	// Remaining are the webhooks that were never attempted
+	Remaining []WebHook
+}
 
-	// Display diff of each WebHook
-	hookRefs := make([]reflect.Value, len(HookWrappers))
-	for index, HookWrapper := range HookWrappers {
-		hookRefs[index] = reflect.ValueOf(HookWrapper.Hook)
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("Received %s. %d webhook(s) were changed and %d were not attempted", e.Signal, e.Changed, len(e.Remaining))
+}
+
+// changeWebHooks applies a change to multiple webhooks. A failure to change one
+// webhook does not stop the rest from being changed. On SIGINT or SIGTERM the
+// webhook being changed is finished and recorded but no more are started.
+// @arg ctx context.Context - Cancels requests when done
+// @arg webHooks []WebHook
+// @arg auditLog *AuditLog - Records the result of each change. May be nil.
+// @arg action hookAction - Describes the change
+// @arg change func(context.Context, WebHook) error - Changes a single webhook
+// @return error - *InterruptedError if a signal was received, otherwise an
+// aggregate of every failure, or nil if all were changed
+func changeWebHooks(ctx context.Context, webHooks []WebHook, auditLog *AuditLog, action hookAction, change func(context.Context, WebHook) error) error {
+	// Handle signals ourselves so the change in flight is never cut short
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+
+	errorString := ""
+	changed := 0
+	for index, hook := range webHooks {
+		select {
+		case sig := <-interrupts:
+			if errorString != "" {
+				fmt.Print(errorString)
+			}
+			return &InterruptedError{Signal: sig, Changed: changed, Remaining: webHooks[index:]}
+		default:
+		}
+
+		err := change(ctx, hook)
+		auditLog.record(hook, action.Past, err)
+		if err != nil {
+			errorString += fmt.Sprintf("- %s %s : %s\n", au.Red("Error "+action.Gerund+" web hook"), hook.URL, au.Red(err))
+			continue
+		}
+		changed++
+	}
+	if errorString != "" {
+		return errors.New(errorString)
 	}
+	return nil
+}
 
-	// Gather diffs of each duplicate
-	fieldCount := hookRefs[0].NumField()
-	outputs := make([]string, fieldCount)
+// Destroys multiple webhooks. A failure to destroy one webhook does not stop
+// the rest from being destroyed.
+// @arg ctx context.Context - Cancels requests when done
+// @arg webHooks []WebHook
+// @arg auditLog *AuditLog - Records the result of each destroy. May be nil.
+// @return error - See changeWebHooks
+func destroyWebHooks(ctx context.Context, webHooks []WebHook, auditLog *AuditLog) error {
+	return changeWebHooks(ctx, webHooks, auditLog, destroyAction, func(ctx context.Context, hook WebHook) error {
+		return apiClient.DeleteWebHook(ctx, hook)
+	})
+}
+
+// Deactivates multiple webhooks so they stop receiving deliveries. A failure to
+// deactivate one webhook does not stop the rest from being deactivated.
+// @arg ctx context.Context - Cancels requests when done
+// @arg webHooks []WebHook
+// @arg auditLog *AuditLog - Records the result of each deactivation. May be nil.
+// @return error - See changeWebHooks
+func deactivateWebHooks(ctx context.Context, webHooks []WebHook, auditLog *AuditLog) error {
+	return changeWebHooks(ctx, webHooks, auditLog, deactivateAction, func(ctx context.Context, hook WebHook) error {
+		return setWebHookActive(ctx, hook, false)
+	})
+}
+
+// Checks whether any string of one array is present in another
+// @arg array []string
+// @arg inputs []string
+// @return bool
+func containsAnyString(array, inputs []string) bool {
+	for _, item := range array {
+		for _, input := range inputs {
+			if item == input {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Splits a CSV string into its trimmed, non-empty values
+// @arg csv string
+// @return []string
+func splitCSV(csv string) []string {
+	var values []string
+	for _, value := range strings.Split(csv, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Compare two string arrays
+// @return bool True if arrays are different, false if not or an error occurs
+func compareStringArrays(arrayOne, arrayTwo []string) bool {
+	// Check for nil arrays
+	if (arrayOne == nil || arrayTwo == nil) || (len(arrayOne) != len(arrayTwo)) {
+		return true
+	}
+
+	for i := range arrayOne {
+		if arrayOne[i] != arrayTwo[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldDiff formats the value of each field of WebHook for every hook, numbering
+// each hook by its index and alternating colours so the values can be compared
+// @arg hooks []WebHook
+// @arg fieldNames []string - Names of the fields to include, or nil for every field
+// @return []string - Output of each field
+func fieldDiff(hooks []WebHook, fieldNames []string) []string {
+	hookRefs := make([]reflect.Value, len(hooks))
+	for index, hook := range hooks {
+		hookRefs[index] = reflect.ValueOf(hook)
+	}
+
+	// Gather diffs of each hook
+	var outputs []string
 
 	// For each field of WebHook...
-	for fieldIndex := 0; fieldIndex < fieldCount; fieldIndex++ {
+	for fieldIndex := 0; fieldIndex < hookRefs[0].NumField(); fieldIndex++ {
+		field := hookRefs[0].Type().Field(fieldIndex)
+		if field.Tag.Get("diff") == "-" {
+			continue
+		}
+		fieldName := field.Name
+		if fieldNames != nil && !containsAnyString(fieldNames, []string{fieldName}) {
+			continue
+		}
+
 		// Build output string by iterating over field of each item
 		colourBool := true
 
 		// Add name of field
-		outputs[fieldIndex] = fmt.Sprintf("    %s\n", Bold(Gray(hookRefs[0].Type().Field(fieldIndex).Name)))
+		output := fmt.Sprintf("    %s\n", au.Bold(au.Gray(fieldName)))
 
 		// Add value of field for each hook
 		for hookIndex := 0; hookIndex < len(hookRefs); hookIndex++ {
 			if colourBool {
-				outputs[fieldIndex] += fmt.Sprintf("     %s    %s\n", Brown(fmt.Sprint("- ", hookIndex, ":")), Brown(hookRefs[hookIndex].Field(fieldIndex).Interface()))
+				output += fmt.Sprintf("     %s    %s\n", au.Brown(fmt.Sprint("- ", hookIndex, ":")), au.Brown(hookRefs[hookIndex].Field(fieldIndex).Interface()))
 			} else {
-				outputs[fieldIndex] += fmt.Sprintf("     %s    %s\n", Cyan(fmt.Sprint("- ", hookIndex, ":")), Cyan(hookRefs[hookIndex].Field(fieldIndex).Interface()))
+				output += fmt.Sprintf("     %s    %s\n", au.Cyan(fmt.Sprint("- ", hookIndex, ":")), au.Cyan(hookRefs[hookIndex].Field(fieldIndex).Interface()))
 			}
 			colourBool = !colourBool
 		}
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
+
+// Output the diff of two webhooks and allow user to select one to return
+// Marks hooks if user chooses to destroy them
+// @arg in io.Reader - Source of the selection, or nil for stdin
+// @arg out io.Writer - Destination of the diff and prompts, or nil for stdout
+// @arg HookWrappers ...*HookWrapper - Duplicates to compare
+// @return error - If in ends before a valid selection is made
+func duplicateDiff(in io.Reader, out io.Writer, HookWrappers ...*HookWrapper) error {
+	// Must have at least two hooks to compare
+	if len(HookWrappers) < 1 {
+		return nil
+	}
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+
+	fmt.Fprintln(out, au.Bold(au.Magenta("\n* * * * * * * * * * *\n   DUPLICATE FOUND\n* * * * * * * * * * *\n")))
+
+	// Display diff of each WebHook
+	hooks := make([]WebHook, len(HookWrappers))
+	for index, HookWrapper := range HookWrappers {
+		hooks[index] = HookWrapper.Hook
 	}
 
 	// Print output of diffs
-	for i := range outputs {
-		fmt.Println(outputs[i])
+	for _, output := range fieldDiff(hooks, nil) {
+		fmt.Fprintln(out, output)
 	}
 
 	// Accept user input to choose webhook to return
-	fmt.Println(Bold(Gray("Select duplicates to remove (using a CSV string e.g. 0,1) or 'n' for none:")))
+	fmt.Fprintln(out, au.Bold(au.Gray("Select duplicates to remove (using a CSV string e.g. 0,1) or 'n' for none:")))
 
-	// Used for user input
-	var input string
 	for {
 		// Read input
-		fmt.Scanln(&input)
+		var input string
+		if _, err := fmt.Fscanln(in, &input); err == io.EOF {
+			return errors.New("input ended before duplicates were selected")
+		}
 		// Remove spaces and set uppercase
 		input = strings.Replace(strings.ToUpper(input), " ", "", -1)
 		// Split into array
@@ -502,7 +1487,7 @@ func duplicateDiff(HookWrappers ...*HookWrapper) error {
 
 		// Check if 'n' was selected
 		if len(splitInput) == 1 && splitInput[0] == "N" {
-			fmt.Printf("%s\n\n%s\n\n", Bold(Gray("You chose to destroy no duplicates")), Bold(Magenta("\n* * * * * * * * * * *\n        DONE\n* * * * * * * * * * *\n")))
+			fmt.Fprintf(out, "%s\n\n%s\n\n", au.Bold(au.Gray("You chose to destroy no duplicates")), au.Bold(au.Magenta("\n* * * * * * * * * * *\n        DONE\n* * * * * * * * * * *\n")))
 			break
 		}
 
@@ -520,218 +1505,775 @@ func duplicateDiff(HookWrappers ...*HookWrapper) error {
 			}
 
 			// Break if input is not in range of possible options
-			if intInput < 0 || intInput > len(hookRefs)-1 {
+			if intInput < 0 || intInput > len(hooks)-1 {
 				valid = false
 				break
 			}
 
-			intSplitInput[i] = intInput
-		}
+			intSplitInput[i] = intInput
+		}
+
+		// Check if input is valid
+		if valid == false {
+			fmt.Fprintln(out, au.Red("Invalid choice. Please try again using CSV format."))
+			continue
+		}
+
+		// Set destroy of each hook
+		for _, hookIndex := range intSplitInput {
+			HookWrappers[hookIndex].Destroy = true
+		}
+
+		// Print confirmation message and exit input loop
+		output := strings.Join(splitInput, ",")
+		fmt.Fprintf(out, "%s %s\n\n%s\n\n", au.Bold(au.Gray("You chose option(s)")), au.Bold(au.Brown(output)), au.Bold(au.Magenta("\n* * * * * * * * * * *\n       DONE       \n* * * * * * * * * * *\n")))
+		break
+	}
+	return nil
+}
+
+// wrapWebHookList converts webhooks to HookWrappers, keeping their order
+// @arg webHooks []WebHook
+// @return []*HookWrapper
+func wrapWebHookList(webHooks []WebHook) []*HookWrapper {
+	hooks := make([]*HookWrapper, len(webHooks))
+	for i, hook := range webHooks {
+		hooks[i] = &HookWrapper{Hook: hook}
+	}
+	return hooks
+}
+
+// Converts WebHooks to a map of HookWrappers keyed by hook API URL
+// @arg webHooks WebHooks
+// @return map[string]*HookWrapper
+func wrapWebHooks(webHooks WebHooks) map[string]*HookWrapper {
+	hooksMap := make(map[string]*HookWrapper, len(webHooks.Hooks))
+	for _, hook := range webHooks.Hooks {
+		hooksMap[hook.URL] = &HookWrapper{Hook: hook}
+	}
+	return hooksMap
+}
+
+// Groups hooks by normalized config URL and marks every hook in a group of more than one as
+// a duplicate. Groups are ordered by config URL and hooks within a group by ID so
+// the result does not depend on map iteration order.
+// @arg hooksMap map[string]*HookWrapper
+// @return [][]*HookWrapper - Groups of duplicate hooks
+func groupDuplicates(hooksMap map[string]*HookWrapper) [][]*HookWrapper {
+	hooks := make([]WebHook, 0, len(hooksMap))
+	for _, hook := range hooksMap {
+		hooks = append(hooks, hook.Hook)
+	}
+
+	var groups [][]*HookWrapper
+	for _, duplicateHooks := range webhookit.DuplicateGroups(hooks) {
+		group := make([]*HookWrapper, 0, len(duplicateHooks))
+		for _, hook := range duplicateHooks {
+			group = append(group, hooksMap[hook.URL])
+		}
+		markDuplicates(group...)
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// readURLList reads a newline delimited list of config URLs, ignoring blank lines
+// and lines starting with #
+// @arg filePath string
+// @return map[string]bool - Set of the normalized URLs
+// @return error
+func readURLList(filePath string) (map[string]bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	urls := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls[webhookit.NormalizeConfigURL(line)] = true
+	}
+	return urls, scanner.Err()
+}
+
+// hookSortOrders are the orders hooks can be sorted in by sortHookWrappers
+var hookSortOrders = []string{"url", "code", "id"}
+
+// Sorts the hooks of a map of HookWrappers by config URL, last response code or ID.
+// Ties are broken by config URL then ID so the order is always the same.
+// @arg hooksMap map[string]*HookWrapper
+// @arg by string - One of hookSortOrders
+// @return []*HookWrapper
+func sortHookWrappers(hooksMap map[string]*HookWrapper, by string) []*HookWrapper {
+	hooks := make([]*HookWrapper, 0, len(hooksMap))
+	for _, hook := range hooksMap {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		a, b := hooks[i].Hook, hooks[j].Hook
+		if by == "code" && a.LastResponse.Code != b.LastResponse.Code {
+			return a.LastResponse.Code < b.LastResponse.Code
+		}
+		if by != "id" && a.Config.URL != b.Config.URL {
+			return a.Config.URL < b.Config.URL
+		}
+		return a.ID < b.ID
+	})
+	return hooks
+}
+
+// chooseHooksToDestroy prompts for each hook whether to destroy or keep it
+// @arg hooks []*HookWrapper
+// @arg action hookAction - What is done to the chosen hooks
+// @return []*HookWrapper - The hooks chosen to destroy
+func chooseHooksToDestroy(hooks []*HookWrapper, action hookAction) []*HookWrapper {
+	var chosen []*HookWrapper
+	for index, hook := range hooks {
+		fmt.Printf("%s %s\n%s\n", au.Bold(au.Gray(fmt.Sprintf("[%d/%d]", index+1, len(hooks)))), au.Bold(au.Magenta(hook.Hook.Repo)), statusToString(hook.Hook))
+		fmt.Printf("%s ", au.Bold(fmt.Sprintf("%s this webhook? [y/N]", action.Title)))
+
+		var input string
+		fmt.Scanln(&input)
+		input = strings.ToLower(strings.TrimSpace(input))
+
+		if input == "y" || input == "yes" {
+			chosen = append(chosen, hook)
+			fmt.Println(au.Brown(fmt.Sprintf("Will be %s\n", action.Past)))
+		} else {
+			fmt.Println(au.Green("Kept\n"))
+		}
+	}
+	return chosen
+}
+
+// Formats a list of hooks to destroy, grouped under the name of their repo
+// @arg hooks []*HookWrapper
+// @return string
+func destroyListToString(hooks []*HookWrapper) string {
+	output := ""
+	repoName := ""
+	for _, hook := range hooks {
+		if hook.Hook.Repo != repoName || output == "" {
+			repoName = hook.Hook.Repo
+			output += fmt.Sprintf("\n%s\n\n", au.Bold(au.Magenta(repoName)))
+		}
+		if hook.Hook.Config.URL == "" {
+			output += fmt.Sprintf("%s => %s\n", au.Bold(au.Gray(hook.Hook.URL)), au.Brown(hook.Hook.Name))
+		} else {
+			output += fmt.Sprintf("%s => %s\n", au.Bold(au.Gray(hook.Hook.URL)), au.Brown(hook.Hook.Config.URL))
+		}
+	}
+	return output
+}
+
+// Mark an array of HookWrappers as Duplicated
+func markDuplicates(HookWrappers ...*HookWrapper) {
+	for _, HookWrapper := range HookWrappers {
+		HookWrapper.Duplicate = true
+	}
+}
+
+// confirmDestroy asks the user to enter a random passphrase before destroying webhooks.
+// Exits if stdin is not a terminal since the prompt could never be answered.
+// @arg assumeYes bool - Skip the prompt and confirm
+// @return bool - Whether the passphrase was entered correctly
+func confirmDestroy(assumeYes bool) bool {
+	question := fmt.Sprintf("%s %s", au.Bold("Do you wish to destroy the selected web hooks? Once done it"), au.Bold(au.Red("cannot be reverted.\n")))
+	return confirmPassPhrase(question, "destruction", assumeYes)
+}
+
+// confirmDeactivate asks the user to enter a random passphrase before deactivating webhooks.
+// Exits if stdin is not a terminal since the prompt could never be answered.
+// @arg assumeYes bool - Skip the prompt and confirm
+// @return bool - Whether the passphrase was entered correctly
+func confirmDeactivate(assumeYes bool) bool {
+	question := fmt.Sprintf("%s\n", au.Bold("Do you wish to deactivate the selected web hooks? They can be reactivated with -activate."))
+	return confirmPassPhrase(question, "deactivation", assumeYes)
+}
+
+// confirmPassPhrase asks a question and requires the user to enter a random passphrase
+// to confirm. Exits if stdin is not a terminal since the prompt could never be answered,
+// unless knownPassPhrase is set in which case it is read from stdin instead.
+// @arg question string - Question to print before the passphrase
+// @arg action string - Name of the action being confirmed e.g. destruction
+// @arg assumeYes bool - Skip the prompt and confirm
+// @return bool - Whether the passphrase was entered correctly
+func confirmPassPhrase(question, action string, assumeYes bool) bool {
+	if assumeYes {
+		fmt.Println(au.Bold(au.Brown("Skipping confirmation as -yes was given.")))
+		return true
+	}
+	if knownPassPhrase != "" {
+		fmt.Printf("%sEnter the value given with -confirm to continue or anything else to abort.\n", question)
+
+		var input string
+		fmt.Scanln(&input)
+		return strings.TrimSpace(input) == knownPassPhrase
+	}
+	if !isTerminal(os.Stdin) {
+		printError("Cannot confirm " + action + " as stdin is not a terminal. Use -yes to continue without confirmation or -confirm to confirm from stdin.")
+	}
+
+	passPhrase := generatePassPhrase(confirmLength)
+	fmt.Printf("%sEnter `%s` to continue or anything else to abort.\n", question, au.Brown(passPhrase))
+
+	var input string
+
+	fmt.Scanln(&input)
+	input = strings.TrimSpace(strings.ToUpper(input))
+
+	return input == passPhrase
+}
 
-		// Check if input is valid
-		if valid == false {
-			fmt.Println(Red("Invalid choice. Please try again using CSV format."))
-			continue
-		}
+// DestroyOptions holds the options of the destroy process
+type DestroyOptions struct {
+	Types string
+	// ExcludeTypes inverts Types so hooks whose HTTP status codes do not match are
+	// destroyed. Hooks that were never triggered or report no status never match.
+	ExcludeTypes       bool
+	Duplicates         bool
+	Untriggered        bool
+	NeverSucceeded     bool
+	ListHooksToDestroy bool
+	Backup             string
+	// DryRun matches and lists hooks without destroying them. Takes precedence over Yes.
+	DryRun bool
+	// Yes destroys without asking for confirmation
+	Yes bool
+	// URLMatch destroys hooks whose config URL matches. Nil matches no hooks.
+	URLMatch *regexp.Regexp
+	// CrossRepoDuplicates groups Duplicates by config URL across every repo scanned
+	// instead of within each repo
+	CrossRepoDuplicates bool
+	// URLList destroys hooks whose normalized config URL is in the set. Nil matches no hooks.
+	URLList map[string]bool
+	// URLOnly disables status code matching so only URLMatch and URLList are used
+	URLOnly bool
+	// Limit is the maximum number of hooks to destroy. Zero is unlimited.
+	Limit int
+	// Interactive asks whether to destroy or keep each matched hook
+	Interactive bool
+	// AuditLog is the path of a file a line is appended to for each destroyed hook. Empty disables it.
+	AuditLog string
+	// Deactivate sets matched hooks inactive instead of destroying them
+	Deactivate bool
+}
 
-		// Set destroy of each hook
-		for _, hookIndex := range intSplitInput {
-			HookWrappers[hookIndex].Destroy = true
-		}
+// hookAction describes what is done to the webhooks matched by destroy
+type hookAction struct {
+	Title  string
+	Verb   string
+	Past   string
+	Gerund string
+	Noun   string
+}
 
-		// Print confirmation message and exit input loop
-		output := strings.Join(splitInput, ",")
-		fmt.Printf("%s %s\n\n%s\n\n", Bold(Gray("You chose option(s)")), Bold(Brown(output)), Bold(Magenta("\n* * * * * * * * * * *\n       DONE       \n* * * * * * * * * * *\n")))
-		break
+var (
+	destroyAction    = hookAction{Title: "Destroy", Verb: "destroy", Past: "destroyed", Gerund: "destroying", Noun: "Destruction"}
+	deactivateAction = hookAction{Title: "Deactivate", Verb: "deactivate", Past: "deactivated", Gerund: "deactivating", Noun: "Deactivation"}
+)
+
+// action returns what is done to the matched webhooks
+// @return hookAction
+func (o DestroyOptions) action() hookAction {
+	if o.Deactivate {
+		return deactivateAction
 	}
-	return nil
+	return destroyAction
 }
 
-// Mark an array of HookWrappers as Duplicated
-func markDuplicates(HookWrappers ...*HookWrapper) {
-	for _, HookWrapper := range HookWrappers {
-		HookWrapper.Duplicate = true
+// criteria returns the criteria selecting the webhooks to destroy
+// @arg types []string - Types returned by webhookit.ParseTypes
+// @return webhookit.DestroyCriteria
+// @return error
+func (o DestroyOptions) criteria(types []string) (webhookit.DestroyCriteria, error) {
+	criteria := webhookit.DestroyCriteria{
+		ExcludeTypes:   o.ExcludeTypes,
+		URLMatch:       o.URLMatch,
+		URLList:        o.URLList,
+		Untriggered:    o.Untriggered,
+		NeverSucceeded: o.NeverSucceeded,
+		// Inactive hooks are already deactivated
+		ActiveOnly: o.Deactivate,
+	}
+	if !o.URLOnly {
+		typesRegex, err := webhookit.CompileTypes(types)
+		if err != nil {
+			return webhookit.DestroyCriteria{}, err
+		}
+		criteria.Types = typesRegex
 	}
+	return criteria, nil
 }
 
 // Executes the destroy process of webhooks
-// @arg typesFlag string
-// @arg duplicatesFlag bool
-// @arg untriggeredFlag bool
-// @arg listHooksToDestroyFlag bool
-// @arg backupFlag string
-// @return error
-func executeDestroy(typesFlag string, duplicatesFlag, untriggeredFlag, listHooksToDestroyFlag bool, backupFlag string) error {
+// @arg ctx context.Context - Cancels requests when done
+// @arg options DestroyOptions
+// @arg filter HookFilter
+// @return error - Non-nil if the webhooks of any repo could not be retrieved or any
+// webhook could not be destroyed, even though the rest were
+func executeDestroy(ctx context.Context, options DestroyOptions, filter HookFilter) error {
+	action := options.action()
+
 	// Print title
-	title := fmt.Sprintf("%s\n%s\n%s\n", Bold(Gray("* * * * * * * * * * * * * * * * * * * *")), Bold(Brown("            D E S T R O Y")), Bold(Gray("* * * * * * * * * * * * * * * * * * * *")))
+	banner := "            D E S T R O Y"
+	if options.Deactivate {
+		banner = "         D E A C T I V A T E"
+	}
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown(banner)), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
 	fmt.Println(title)
 
 	// Validate types
-	types, err := validateTypesFlag(typesFlag)
+	types, err := webhookit.ParseTypes(options.Types)
 	if err != nil {
 		printError("Invalid type options specified:", err)
 	}
 
+	// Choosing duplicates to destroy is always interactive
+	if options.Duplicates && !isTerminal(os.Stdin) {
+		printError("Cannot choose duplicates to destroy as stdin is not a terminal.")
+	}
+	if options.Interactive && !isTerminal(os.Stdin) {
+		printError("Cannot choose hooks to " + action.Verb + " interactively as stdin is not a terminal.")
+	}
+
 	additionalOutput := ""
-	if duplicatesFlag {
+	if options.Duplicates {
 		additionalOutput += "and duplicates "
 	}
-	if untriggeredFlag {
-		additionalOutput += "and untriggered webhooks"
+	if options.Untriggered {
+		additionalOutput += "and untriggered webhooks "
+	}
+	if options.NeverSucceeded {
+		additionalOutput += "and webhooks that never succeeded "
+	}
+	urlOutput := ""
+	if options.URLMatch != nil {
+		urlOutput += "and config urls matching " + options.URLMatch.String() + " "
+	}
+	if options.URLList != nil {
+		urlOutput += fmt.Sprintf("and %d listed config urls ", len(options.URLList))
+	}
+	additionalOutput += urlOutput
+	matching := "matching"
+	if options.ExcludeTypes {
+		matching = "not matching"
+	}
+	if options.URLOnly {
+		fmt.Printf("%s %s\n", au.Bold(au.Gray("Webhooks to be "+action.Past+" with")), au.Bold(au.Brown(strings.TrimPrefix(urlOutput, "and "))))
+	} else {
+		fmt.Printf("%s %s %s\n", au.Bold(au.Gray("Webhooks to be "+action.Past+" with HTTP status codes "+matching)), au.Bold(au.Brown(types)), au.Bold(au.Brown(additionalOutput)))
 	}
-	fmt.Printf("%s %s %s\n", Bold(Gray("Webhooks to be destroyed with HTTP status codes matching")), Bold(Brown(types)), Bold(Brown(additionalOutput)))
 
-	typesRegexString := convertTypesToRegex(types)
+	criteria, err := options.criteria(types)
+	if err != nil {
+		printError("Error compiling types regex:", err)
+	}
 
-	fmt.Println(Bold(Gray("Checking GitHub repos for validity of webhooks and tagging those to destroy...\n")))
+	fmt.Println(au.Bold(au.Gray("Checking GitHub repos for validity of webhooks and tagging those to " + action.Verb + "...\n")))
 
 	// Array containing indexes of duplicate hooks
 	allWebHooks := WebHooks{}
 	// Total output of hooks
 	var totalOutput string
-	// Total output of hooks to destroy
-	var totalDestroyOutput string
-	// Array to store ID of all hooks to be destroyed
-	var hooksToDestroy []string
+	// Array to store all hooks to be destroyed
+	var hooksToDestroy []*HookWrapper
+	// Hooks of each repo scanned, output once duplicates are chosen
+	type scannedRepo struct {
+		name     string
+		hooksMap map[string]*HookWrapper
+	}
+	var scannedRepos []scannedRepo
+	// Hooks of every repo by API URL, grouped into duplicates once every repo is scanned
+	allHooksMap := make(map[string]*HookWrapper)
+	// Number of repos whose webhooks could not be retrieved
+	failedRepos := 0
 
 	// For each repo...
-	for _, repo := range reposContainer.Repos {
+	for index, repo := range reposContainer.Repos {
+		if deadlineReached(ctx, index, len(reposContainer.Repos)) {
+			break
+		}
+		printProgress(index, len(reposContainer.Repos), repo.Name)
+
 		// Get web hooks
-		webHooks, err := getWebHooks(repo.Name)
+		webHooks, err := getWebHooks(ctx, repo)
 		if err != nil {
-			fmt.Printf("%s %s\n\n", Red("Failed to retrieve web hooks:"), Red(err))
+			clearProgress()
+			fmt.Print(repoErrorMessage(err))
+			if isRepoFailure(err) {
+				failedRepos++
+			}
 			continue
 		}
 
 		// Add webHooks to allWebHooks for backup
-		if backupFlag != "" {
+		if options.Backup != "" {
 			allWebHooks.Hooks = append(allWebHooks.Hooks, webHooks.Hooks...)
 		}
 
+		// Only consider hooks passing the filter
+		webHooks.Hooks = webhookit.FilterWebHooks(webHooks.Hooks, filter)
+
 		// Convert WebHooks to map of HookWrappers
-		hooksMap := make(map[string]*HookWrapper, len(webHooks.Hooks))
-		for _, hook := range webHooks.Hooks {
-			hooksMap[hook.URL] = &HookWrapper{
-				Hook: hook,
-				Code: strings.ToUpper(strconv.Itoa(hook.LastResponse.Code)),
+		hooksMap := wrapWebHooks(webHooks)
+		if options.CrossRepoDuplicates {
+			for hookURL, hook := range hooksMap {
+				allHooksMap[hookURL] = hook
 			}
-		}
-		// For each hook...
-		for index := range hooksMap {
-			currentItem := hooksMap[index].Hook.URL
-			var duplicateHookWrappers []*HookWrapper
-			duplicateHookWrappers = append(duplicateHookWrappers, hooksMap[index])
-
-			for dIndex := range hooksMap {
-				iterateItem := hooksMap[dIndex].Hook.URL
-				// Skip the same hook or an item already marked as duplicate
-				if currentItem == iterateItem || hooksMap[iterateItem].Duplicate == true {
-					continue
-				}
-
-				// Check if hook is a duplicate
-				if hooksMap[currentItem].Hook.Config.URL != "" && (hooksMap[currentItem].Hook.Config.URL == hooksMap[iterateItem].Hook.Config.URL) {
-					// Mark hooks as duplicate
-					hooksMap[currentItem].Duplicate = true
-					hooksMap[iterateItem].Duplicate = true
-
-					// Add iterate hook to duplicate hooks
-					duplicateHookWrappers = append(duplicateHookWrappers, hooksMap[iterateItem])
+		} else {
+			// Perform diff of each group of duplicates found
+			for _, duplicateHookWrappers := range groupDuplicates(hooksMap) {
+				if options.Duplicates {
+					clearProgress()
+					err := duplicateDiff(os.Stdin, os.Stdout, duplicateHookWrappers...)
+					if err != nil {
+						printError("Error occured generating duplicate diff:", err)
+					}
 				}
 			}
+		}
 
-			// Perform diff if duplicates found
-			if len(duplicateHookWrappers) > 1 && duplicatesFlag {
-				err := duplicateDiff(duplicateHookWrappers...)
-				if err != nil {
-					printError("Error occured generating duplicate diff:", err)
-				}
+		// Check if each hook should be destroyed
+		for _, hook := range hooksMap {
+			if criteria.Matches(hook.Hook) {
+				hook.Destroy = true
+				hook.Deactivate = options.Deactivate
 			}
+		}
 
-			// Check if hook should be destroyed
-			typesRegex, err := regexp.Compile(typesRegexString)
-			if err != nil {
-				fmt.Printf("%s %s\n", Red("Error compiling types regex"), Red(err))
-				continue
-			}
-			if typesRegex.MatchString(hooksMap[currentItem].Code) || (untriggeredFlag && hooksMap[currentItem].Code == "0") {
-				hooksMap[currentItem].Destroy = true
+		scannedRepos = append(scannedRepos, scannedRepo{name: repo.Name, hooksMap: hooksMap})
+	}
+
+	clearProgress()
+
+	// Perform diff of each group of hooks sharing a config URL across repos
+	if options.CrossRepoDuplicates && ctx.Err() == nil {
+		groups := groupDuplicates(allHooksMap)
+		fmt.Printf("%s %d %s\n", au.Bold(au.Gray("Found")), au.Bold(au.Brown(len(groups))), au.Bold(au.Gray("config url(s) shared by more than one webhook across repos")))
+		for _, duplicateHookWrappers := range groups {
+			if err := duplicateDiff(os.Stdin, os.Stdout, duplicateHookWrappers...); err != nil {
+				printError("Error occured generating duplicate diff:", err)
 			}
 		}
+	}
 
-		// Print name of repo
-		printName := fmt.Sprintf("%s\n\n", Bold(Magenta(repo.Name)))
-		totalOutput += printName
+	for _, scanned := range scannedRepos {
+		// Print name of repo. Compact output names the repo on every line instead.
+		if !compactOutput {
+			printName := fmt.Sprintf("%s\n\n", au.Bold(au.Magenta(scanned.name)))
+			totalOutput += printName
+		}
 
 		// Determine which hooks to destroy then output all results
-		includeTitle := false
-		for _, hook := range hooksMap {
+		for _, hook := range sortHookWrappers(scanned.hooksMap, "id") {
 			if hook.canDestroy() {
-				if !includeTitle {
-					totalDestroyOutput += "\n" + printName
-					includeTitle = true
-				}
-				if hook.Hook.Config.URL == "" {
-					totalDestroyOutput += fmt.Sprintf("%s => %s\n", Bold(Gray(hook.Hook.URL)), Brown(hook.Hook.Name))
-				} else {
-					totalDestroyOutput += fmt.Sprintf("%s => %s\n", Bold(Gray(hook.Hook.URL)), Brown(hook.Hook.Config.URL))
-				}
-				hooksToDestroy = append(hooksToDestroy, hook.Hook.URL)
+				hooksToDestroy = append(hooksToDestroy, hook)
+			}
+			if compactOutput {
+				totalOutput += compactHook(scanned.name, hook)
+			} else {
+				totalOutput += renderHook(scanned.name, hook) + "\n"
 			}
-			totalOutput += hook.ToString() + "\n"
 		}
 
 		// Newline to space out each repo
-		totalOutput += "\n"
+		if !compactOutput {
+			totalOutput += "\n"
+		}
+	}
+	if compactOutput {
+		totalOutput = alignColumns(totalOutput)
 	}
 
 	// Print totalOutput
 	fmt.Println(totalOutput)
 
+	// No time is left to destroy anything
+	if ctx.Err() != nil {
+		fmt.Println(au.Red(action.Noun + " skipped as the deadline was reached."))
+		return ctx.Err()
+	}
+
+	// Hooks of the other repos are still destroyed but the run has failed
+	repoErr := scanError(ctx, failedRepos, len(reposContainer.Repos))
+
 	// Return if no hooks to destroy were found
 	hookCount := len(hooksToDestroy)
 	if hookCount == 0 {
-		fmt.Println(Green("Found no hooks to destroy."))
-		return nil
+		fmt.Println(au.Green("Found no hooks to " + action.Verb + "."))
+		return repoErr
 	} else {
-		fmt.Println(fmt.Sprintf("%s %d %s\n", Bold(Gray("Found")), Bold(Brown(hookCount)), Bold(Gray("hooks to destroy"))))
+		fmt.Println(fmt.Sprintf("%s %d %s\n", au.Bold(au.Gray("Found")), au.Bold(au.Brown(hookCount)), au.Bold(au.Gray("hooks to "+action.Verb))))
+	}
+
+	// Only destroy up to the limit of hooks
+	if options.Limit > 0 && hookCount > options.Limit {
+		hooksToDestroy = hooksToDestroy[:options.Limit]
+		fmt.Println(fmt.Sprintf("%s %d %s %d %s\n", au.Bold(au.Gray("Only "+action.Gerund+" the first")), au.Bold(au.Brown(options.Limit)), au.Bold(au.Gray("of")), au.Bold(au.Brown(hookCount)), au.Bold(au.Gray("matched hooks"))))
+	}
+
+	// Let the user keep or destroy each matched hook
+	if options.Interactive {
+		hooksToDestroy = chooseHooksToDestroy(hooksToDestroy, action)
+		if len(hooksToDestroy) == 0 {
+			fmt.Println(au.Green("No hooks were chosen to " + action.Verb + "."))
+			return repoErr
+		}
+	}
+	totalDestroyOutput := destroyListToString(hooksToDestroy)
+
+	// A dry run lists the hooks that would be destroyed then stops
+	if options.DryRun {
+		fmt.Printf("%s\n%s\n", au.Magenta("The following webhooks would be "+action.Past+":\n"), totalDestroyOutput)
+		fmt.Println(au.Bold(au.Green("DRY RUN - no webhooks were " + action.Past)))
+		return repoErr
+	}
+
+	// Open the audit log before changing anything so it cannot fail part way
+	auditLog, err := openAuditLog(options.AuditLog)
+	if err != nil {
+		printError("Issue opening audit log:", err)
 	}
+	defer auditLog.Close()
 
-	// Execution of backup. Backup will only occur if a non-empty backupFlag is present
-	if err := executeBackup(backupFlag, allWebHooks); err != nil {
+	// Execution of backup. Backup will only occur if a non-empty backup path is present
+	if err := executeBackup(options.Backup, allWebHooks); err != nil {
 		printError("Backup failed:", err)
 	}
 
 	// If flag is true, print list of all hooks to be destroyed
-	if listHooksToDestroyFlag {
-		fmt.Printf("%s\n%s\n", Magenta("The following webhooks will be destroyed:\n"), totalDestroyOutput)
+	if options.ListHooksToDestroy {
+		fmt.Printf("%s\n%s\n", au.Magenta("The following webhooks will be "+action.Past+":\n"), totalDestroyOutput)
 	}
 
 	// Confirm with user to go ahead with destroys
-	passPhrase := generatePassPhrase(8)
-	fmt.Printf("%s %sEnter `%s` to continue or anything else to abort.\n", Bold("Do you wish to destroy the selected web hooks? Once done it"), Bold(Red("cannot be reverted.\n")), Brown(passPhrase))
+	var confirmed bool
+	if options.Deactivate {
+		confirmed = confirmDeactivate(options.Yes)
+	} else {
+		confirmed = confirmDestroy(options.Yes)
+	}
+	if confirmed {
+		var webHooks []WebHook
+		for _, hook := range hooksToDestroy {
+			webHooks = append(webHooks, hook.Hook)
+		}
+		if options.Deactivate {
+			err = deactivateWebHooks(ctx, webHooks, auditLog)
+		} else {
+			err = destroyWebHooks(ctx, webHooks, auditLog)
+		}
+		var interrupted *InterruptedError
+		if errors.As(err, &interrupted) {
+			fmt.Printf("\n%s\n%s\n", au.Magenta("The following webhooks were not "+action.Past+":\n"), destroyListToString(wrapWebHookList(interrupted.Remaining)))
+			return fmt.Errorf("%s interrupted: %w", action.Noun, err)
+		}
+		if err != nil {
+			return fmt.Errorf("error %s all web hooks\n%w", action.Gerund, err)
+		}
+		fmt.Println(au.Green("\n" + action.Noun + " completed."))
+	} else {
+		fmt.Println(au.Green("\n" + action.Noun + " aborted."))
+	}
 
-	var input string
+	return repoErr
+}
 
-	fmt.Scanln(&input)
-	input = strings.TrimSpace(strings.ToUpper(input))
+// Executes the destroy of a single webhook on a repo by ID
+// @arg ctx context.Context - Cancels requests when done
+// @arg repoName string
+// @arg hookID int
+// @arg options DestroyOptions
+// @return error
+func executeDestroyHook(ctx context.Context, repoName string, hookID int, options DestroyOptions) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("            D E S T R O Y")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	webHooks, err := getWebHooks(ctx, Repo{Name: repoName})
+	if err != nil {
+		printError("Failed to retrieve web hooks:", err)
+	}
+
+	// Find the hook with the given ID
+	var hook *WebHook
+	for i := range webHooks.Hooks {
+		if webHooks.Hooks[i].ID == hookID {
+			hook = &webHooks.Hooks[i]
+			break
+		}
+	}
+	if hook == nil {
+		printError(fmt.Sprintf("Webhook %d not found on %s", hookID, repoName))
+	}
 
-	if input == passPhrase {
-		if err := destroyWebHooks(hooksToDestroy); err != nil {
-			printError("Error destroying all web hooks\n", err)
+	fmt.Printf("%s\n\n%s\n\n", au.Bold(au.Magenta(repoName)), statusToString(*hook))
+
+	if options.DryRun {
+		fmt.Println(au.Bold(au.Green("DRY RUN - no webhooks were destroyed")))
+		return nil
+	}
+
+	auditLog, err := openAuditLog(options.AuditLog)
+	if err != nil {
+		printError("Issue opening audit log:", err)
+	}
+	defer auditLog.Close()
+
+	if confirmDestroy(options.Yes) {
+		err := apiClient.DeleteWebHook(ctx, *hook)
+		auditLog.record(*hook, "destroyed", err)
+		if err != nil {
+			printError("Error destroying web hook\n", err)
 		} else {
-			fmt.Println(Green("\nDestruction completed."))
+			fmt.Println(au.Green("\nDestruction completed."))
 		}
 	} else {
-		fmt.Println(Green("\nDestruction aborted."))
+		fmt.Println(au.Green("\nDestruction aborted."))
+	}
+
+	return nil
+}
+
+// printProgress overwrites the progress line on stderr with the repo being scanned
+// @arg index int - Index of the repo being scanned
+// @arg total int - Number of repos to scan
+// @arg repoName string
+func printProgress(index, total int, repoName string) {
+	if showProgress {
+		fmt.Fprintf(os.Stderr, "\r\033[K[%d/%d] Scanning %s...", index+1, total, repoName)
+	}
+}
+
+// deadlineReached returns whether ctx is done, reporting that the remaining repos are skipped
+// @arg ctx context.Context
+// @arg done int - Number of repos already processed
+// @arg total int - Number of repos to process
+// @return bool
+func deadlineReached(ctx context.Context, done, total int) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	clearProgress()
+	fmt.Fprintln(infoOutput, au.Red(fmt.Sprintf("Deadline reached after %d of %d repo(s). Remaining repos were skipped.\n", done, total)))
+	return true
+}
+
+// isRepoFailure returns whether an error retrieving the webhooks of a repo is a
+// failure, rather than the repo being skipped as archived, disabled or not found
+// @arg err error
+// @return bool
+func isRepoFailure(err error) bool {
+	var skipped *RepoSkippedError
+	return !errors.As(err, &skipped)
+}
+
+// scanError returns the error a mode exits with once it has scanned repos
+// @arg ctx context.Context
+// @arg failedRepos int - Number of repos whose webhooks could not be retrieved
+// @arg total int - Number of repos to scan
+// @return error - The error of ctx if the deadline was reached, otherwise an
+// error counting the failed repos, or nil if there were none
+func scanError(ctx context.Context, failedRepos, total int) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failedRepos > 0 {
+		return fmt.Errorf("failed to retrieve the webhooks of %d of %d repo(s)", failedRepos, total)
+	}
+	return nil
+}
+
+// clearProgress removes the progress line from stderr
+func clearProgress() {
+	if showProgress {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
+
+// colorsEnabled returns whether output should be coloured, honouring the
+// -no-color flag, the NO_COLOR environment variable and whether stdout is a terminal
+// @arg noColorFlag bool
+// @return bool
+func colorsEnabled(noColorFlag bool) bool {
+	if noColorFlag || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal returns whether a file is a terminal
+// @arg file *os.File
+// @return bool
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// stringSliceFlag is a flag that can be repeated, accumulating each value.
+// Each value may also be a CSV list.
+type stringSliceFlag []string
+
+func (f *stringSliceFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringSliceFlag) Set(value string) error {
+	*f = append(*f, splitCSV(value)...)
+	return nil
+}
+
+// headerFlag is a flag of HTTP headers using the syntax 'Name: Value' that can be repeated
+type headerFlag http.Header
+
+func (f headerFlag) String() string {
+	var headers []string
+	for name, values := range f {
+		for _, value := range values {
+			headers = append(headers, name+": "+value)
+		}
 	}
+	return strings.Join(headers, ", ")
+}
 
+func (f headerFlag) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	name := strings.TrimSpace(parts[0])
+	if len(parts) != 2 || name == "" {
+		return fmt.Errorf("expected 'Name: Value' but got %q", value)
+	}
+	http.Header(f).Add(name, strings.TrimSpace(parts[1]))
 	return nil
 }
 
+// countOptions returns how many of the supplied options are set
+// @arg options ...bool
+// @return int
+func countOptions(options ...bool) int {
+	count := 0
+	for _, option := range options {
+		if option {
+			count++
+		}
+	}
+	return count
+}
+
 // Prints an error then exits
 func printError(args ...interface{}) {
-	fmt.Println(Red(args))
+	if syslogLogger != nil {
+		syslogLogger.Err(stripANSI(fmt.Sprint(args...)))
+	}
+	fmt.Println(au.Red(args))
 	os.Exit(1)
 }
 
@@ -739,55 +2281,607 @@ func main() {
 	// Declare flag variables
 	var (
 		filePath               string
-		repoFlag               string
+		repoFlag               stringSliceFlag
+		excludeFlag            stringSliceFlag
+		includeFlag            stringSliceFlag
+		repoFormatFlag         string
 		checkFlag              bool
 		destroyFlag            bool
 		typesFlag              string
+		typesExcludeFlag       string
 		duplicatesFlag         bool
+		crossRepoDupsFlag      bool
 		untriggeredFlag        bool
+		neverSucceededFlag     bool
 		listHooksToDestroyFlag bool
 		backupFlag             string
+		restoreFlag            string
+		createCSVFlag          string
+		diffFlag               string
+		applyFlag              string
+		rateLimitFlag          bool
+		deliveriesFlag         bool
+		activateFlag           bool
+		rawFlag                bool
+		syslogFlag             bool
+		syslogFacilityFlag     string
+		syslogTagFlag          string
+		statusFDFlag           int
+		noCacheFlag            bool
+		serveFlag              string
+		intervalFlag           time.Duration
+		deactivateFlag         bool
+		slowThresholdFlag      time.Duration
+		pruneFlag              bool
+		outputFlag             string
+		noColorFlag            bool
+		eventsFlag             string
+		dryRunFlag             bool
+		failOnBrokenFlag       bool
+		streamFlag             bool
+		quietFlag              bool
+		dupOnlyFlag            bool
+		expectEventsFlag       string
+		templateFlag           string
+		compactFlag            bool
+		sortFlag               string
+		outFileFlag            string
+		countFlag              bool
+		hookIDFlag             int
+		pingFlag               bool
+		orgFlag                string
+		orgReposFlag           string
+		archivedFlag           bool
+		teamFlag               string
+		configFlag             string
+		tokenFileFlag          string
+		providerFlag           string
+		apiURLFlag             string
+		appIDFlag              int64
+		appInstallationIDFlag  int64
+		appPrivateKeyFlag      string
+		verboseFlag            bool
+		veryVerboseFlag        bool
+		timeoutFlag            int
+		deadlineFlag           time.Duration
+		proxyFlag              string
+		caCertFlag             string
+		insecureSkipVerifyFlag bool
+		dupReportFlag          bool
+		yesFlag                bool
+		activeFlag             string
+		urlMatchFlag           string
+		urlOnlyFlag            bool
+		urlListFlag            string
+		headerOverrideAuthFlag bool
+		olderThanFlag          time.Duration
+		createdAfterFlag       string
+		contentTypeFlag        string
+		insecureOnlyFlag       bool
+		migrateURLFlag         string
+		limitFlag              int
+		interactiveFlag        bool
+		auditLogFlag           string
 	)
 
 	// Parse options
-	flag.StringVar(&filePath, "f", "", "File path of JSON file containing repos. Uses filepath as argument.")
-	flag.StringVar(&repoFlag, "r", "", "A single specified repo using the syntax namespace/repo.")
+	flag.StringVar(&configFlag, "config", "", "JSON file of option values e.g. {\"t\": \"4XX\"}. YAML is not supported. Options on the command line take precedence.")
+	flag.StringVar(&tokenFileFlag, "token-file", "", "File containing the API key. Takes precedence over WEBHOOKIT_API_KEY.")
+	flag.Int64Var(&appIDFlag, "app-id", 0, "ID of a GitHub App to authenticate as instead of using an API key.")
+	flag.Int64Var(&appInstallationIDFlag, "app-installation-id", 0, "ID of the installation of the GitHub App to authenticate as.")
+	flag.StringVar(&appPrivateKeyFlag, "app-private-key", "", "PEM private key file of the GitHub App.")
+	flag.StringVar(&providerFlag, "provider", "github", "Hosting provider of the repos: github or gitlab.")
+	flag.StringVar(&apiVersion, "api-version", webhookit.DefaultAPIVersion, "Version of the GitHub REST API to request with the X-GitHub-Api-Version header, or an empty string to send none.")
+	flag.StringVar(&apiURLFlag, "api-url", "", "Base URL of the API of the provider e.g. for a GitHub Enterprise or mock server.")
+	flag.BoolVar(&verboseFlag, "v", false, "Log API requests and responses to stderr.")
+	flag.BoolVar(&veryVerboseFlag, "vv", false, "Log API requests and responses to stderr, including bodies of failed responses.")
+	flag.StringVar(&filePath, "f", "", "File path of JSON file containing repos, or - to read repo names from stdin. Uses filepath as argument.")
+	flag.StringVar(&repoFormatFlag, "repo-format", "", "Format of the -f file: json, text or yaml. Detected from the file extension by default.")
+	flag.Var(&repoFlag, "r", "A specified repo using the syntax namespace/repo. Can be repeated.")
+	flag.Var(customHeaders, "header", "HTTP header added to every request using the syntax 'Name: Value'. Can be repeated.")
+	flag.BoolVar(&headerOverrideAuthFlag, "header-override-auth", false, "Allow -header to replace the Authorization header of the API key.")
+	flag.Var(&includeFlag, "include", "CSV list of glob patterns of repos to scan e.g. service-*. Other repos are skipped. Can be repeated.")
+	flag.Var(&excludeFlag, "exclude", "CSV list of repos to skip. Supports glob patterns e.g. org/internal-*. Can be repeated.")
+	flag.StringVar(&orgFlag, "org", "", "An organization whose org-level webhooks are used.")
+	flag.StringVar(&orgReposFlag, "org-repos", "", "An organization whose repos are all used.")
+	flag.BoolVar(&ignoreNotFound, "ignore-404", false, "Skip repos that respond with 404, e.g. renamed or deleted repos of a stale repo list, listing them once at the end instead of printing an error for each.")
+	flag.StringVar(&teamFlag, "team", "", "A GitHub team, as org/team-slug, whose repos are all used.")
+	flag.BoolVar(&archivedFlag, "archived", true, "Include archived repos when using -org-repos or -team.")
 	flag.BoolVar(&checkFlag, "c", false, "Check repos for broken webhooks.")
 	flag.BoolVar(&destroyFlag, "d", false, "Destroy broken webhooks.")
+	flag.BoolVar(&deactivateFlag, "deactivate", false, "Deactivate broken webhooks instead of destroying them.")
+	flag.BoolVar(&activateFlag, "activate", false, "Activate inactive webhooks.")
+	flag.StringVar(&serveFlag, "serve", "", "Address to serve the results of checks run every -interval on e.g. :8080.")
+	flag.DurationVar(&intervalFlag, "interval", defaultServeInterval, "Time between the checks of -serve e.g. 10m.")
+	flag.BoolVar(&rawFlag, "raw", false, "Print the raw JSON responses listing the webhooks of repos.")
+	flag.StringVar(&migrateURLFlag, "migrate-url", "", "Change the config url of webhooks using the syntax old=new.")
+	flag.BoolVar(&dupReportFlag, "dup-report", false, "Report config urls used by more than one webhook of a repo.")
+	flag.BoolVar(&deliveriesFlag, "deliveries", false, "Report the response times of recent deliveries of webhooks.")
+	flag.DurationVar(&slowThresholdFlag, "slow-threshold", defaultSlowThreshold, "Response time above which -deliveries flags a receiver as slow.")
+	flag.BoolVar(&rateLimitFlag, "rate-limit", false, "Print the remaining API requests and when the limit resets.")
+	flag.BoolVar(&pingFlag, "ping", false, "Ping webhooks so GitHub redelivers to them.")
+	flag.StringVar(&typesExcludeFlag, "types-exclude", "", "CSV list of HTTP status code types to keep, destroying webhooks with any other status code e.g. 2XX. Cannot be used with -t.")
 	flag.StringVar(&typesFlag, "t", "3XX,4XX,5XX", "CSV list of HTTP status code types to destroy e.g. 2XX, 501 or 'none' to disable HTTP status code matching")
+	flag.StringVar(&urlMatchFlag, "url-match", "", "Regular expression of config urls to destroy, in addition to matching status codes. With -activate, only matching config urls are activated.")
+	flag.StringVar(&urlListFlag, "url-list", "", "File of config urls to destroy, one per line, in addition to matching status codes.")
+	flag.BoolVar(&urlOnlyFlag, "url-only", false, "Only destroy webhooks matching -url-match or -url-list, ignoring status codes.")
 	flag.BoolVar(&duplicatesFlag, "ds", false, "Include duplicates webhooks when destroying.")
+	flag.BoolVar(&crossRepoDupsFlag, "cross-repo-dups", false, "Treat webhooks sharing a config url in different repos as duplicates when destroying with -ds.")
 	flag.BoolVar(&untriggeredFlag, "u", false, "Include untriggered webhooks when destroying.")
+	flag.BoolVar(&neverSucceededFlag, "never-succeeded", false, "Include webhooks whose last response was not 2XX, whatever its status code, when destroying.")
+	flag.StringVar(&eventsFlag, "events", "", "CSV list of events e.g. push,pull_request. Only hooks subscribed to at least one are considered.")
+	flag.IntVar(&hookIDFlag, "hook-id", 0, "ID of a single webhook to destroy. Must be used with -r.")
+	flag.BoolVar(&dryRunFlag, "dry-run", false, "List hooks that would be destroyed, migrated, activated or created without changing them.")
+	flag.IntVar(&limitFlag, "limit", 0, "Maximum number of webhooks to destroy in this run. 0 is unlimited.")
+	flag.StringVar(&knownPassPhrase, "confirm", "", "Known value to enter instead of a random passphrase when confirming, read from stdin even if it is not a terminal. Intended for scripted runs that still require confirmation.")
+	flag.IntVar(&confirmLength, "confirm-length", defaultConfirmLength, "Number of letters in the confirmation passphrase.")
+	flag.StringVar(&auditLogFlag, "audit-log", "", "File to append a line to for each webhook destroyed or deactivated. Uses filepath as argument.")
+	flag.BoolVar(&interactiveFlag, "interactive", false, "Ask whether to destroy or keep each matched webhook.")
+	flag.BoolVar(&yesFlag, "yes", false, "Destroy, migrate or activate without asking for confirmation. Intended for automation.")
+	flag.DurationVar(&olderThanFlag, "older-than", 0, "Only consider webhooks last updated longer ago than the duration e.g. 720h.")
+	flag.StringVar(&createdAfterFlag, "created-after", "", "Only consider webhooks created after the RFC3339 time or YYYY-MM-DD date.")
+	flag.StringVar(&contentTypeFlag, "content-type", "", "Only consider webhooks with the content type: json or form.")
+	flag.BoolVar(&insecureOnlyFlag, "insecure-only", false, "Only consider webhooks with no secret configured.")
+	flag.StringVar(&activeFlag, "active", "", "Only consider active (true) or inactive (false) webhooks.")
 	flag.BoolVar(&listHooksToDestroyFlag, "l", false, "List hooks to be destroyed before confirmation.")
 	flag.StringVar(&backupFlag, "b", "", "Backups webhooks to JSON file. Uses filepath as argument.")
+	flag.BoolVar(&failOnBrokenFlag, "fail-on-broken", false, "Exit with status 2 if check finds any broken webhooks.")
+	flag.BoolVar(&countFlag, "count", false, "Only print the number of hooks found of each kind when checking.")
+	flag.StringVar(&outFileFlag, "out-file", "", "Write check results to a file and only print the summary. Uses filepath as argument.")
+	flag.BoolVar(&quietFlag, "quiet", false, "Only print broken and never triggered webhooks when checking.")
+	flag.StringVar(&expectEventsFlag, "expect-events", "", "CSV list of the exact events every checked webhook must subscribe to e.g. push,pull_request. Webhooks subscribed to other events are flagged.")
+	flag.BoolVar(&dupOnlyFlag, "dup-only", false, "Only print duplicate webhooks when checking.")
+	flag.BoolVar(&streamFlag, "stream", false, "Print check results of each repo as soon as it is checked. Supports text and csv output.")
+	flag.StringVar(&outputFlag, "o", "text", "Output format of check results: text, json, ndjson or csv.")
+	flag.StringVar(&sortFlag, "sort", "url", "Order of the webhooks of each repo when checking: url, code or id.")
+	flag.StringVar(&templateFlag, "template", "", "Go text/template each webhook is printed with instead of the default text output, or @file to read it from a file.")
+	flag.BoolVar(&compactFlag, "compact", false, "Print each webhook in text output on a single line of aligned columns.")
+	flag.BoolVar(&noColorFlag, "no-color", false, "Disable coloured output.")
+	flag.StringVar(&applyFlag, "apply", "", "Create, update and optionally prune webhooks to match a JSON spec file. Uses filepath as argument.")
+	flag.BoolVar(&pruneFlag, "prune", false, "Destroy webhooks not in the spec when using -apply.")
+	flag.StringVar(&restoreFlag, "restore", "", "Restore webhooks from a JSON backup file. Uses filepath as argument.")
+	flag.StringVar(&createCSVFlag, "create-csv", "", "Create webhooks from the rows of a CSV file with repo, url, events and content_type columns. Uses filepath as argument.")
+	flag.StringVar(&diffFlag, "diff", "", "Compare current webhooks to a JSON backup file. Uses filepath as argument.")
+	flag.DurationVar(&requestDelay, "delay", defaultRequestDelay, "Minimum delay between API requests e.g. 100ms. Use 0 to disable throttling.")
+	flag.StringVar(&proxyFlag, "proxy", "", "Proxy URL to route API requests through. Defaults to HTTP_PROXY/HTTPS_PROXY.")
+	flag.StringVar(&caCertFlag, "ca-cert", "", "PEM file of CA certificates to trust in addition to the system cert pool.")
+	flag.BoolVar(&insecureSkipVerifyFlag, "insecure-skip-verify", false, "Do not verify TLS certificates of the API. Only for development environments.")
+	flag.DurationVar(&deadlineFlag, "deadline", 0, "Maximum time the whole run may take e.g. 10m, after which partial results are printed. 0 is unlimited.")
+	flag.IntVar(&timeoutFlag, "timeout", defaultTimeout, "Timeout of each API request in seconds.")
+	flag.StringVar(&cacheDir, "cache", "", "Directory to cache the webhooks of each repo in, reused within -cache-ttl.")
+	flag.DurationVar(&cacheTTL, "cache-ttl", defaultCacheTTL, "How long cached webhooks are reused for e.g. 1h.")
+	flag.BoolVar(&noCacheFlag, "no-cache", false, "Fetch fresh webhooks even if cached, still updating the cache.")
+	flag.IntVar(&statusFDFlag, "status-fd", 0, "File descriptor a JSON summary of a check is written to e.g. 3.")
+	flag.BoolVar(&syslogFlag, "syslog", false, "Log informational and error output and check results to the local syslog daemon.")
+	flag.StringVar(&syslogFacilityFlag, "syslog-facility", "user", "Syslog facility used with -syslog e.g. local0.")
+	flag.StringVar(&syslogTagFlag, "syslog-tag", defaultSyslogTag, "Syslog tag used with -syslog.")
+	flag.DurationVar(&repoTimeout, "repo-timeout", 0, "Maximum time spent retrieving the webhooks of each repo e.g. 30s. Slower repos are skipped. 0 disables it.")
+	flag.IntVar(&maxRetries, "max-retries", defaultMaxRetries, "Maximum number of times to retry a rate limited or failed API request.")
 	flag.Parse()
 
+	// Fill in options not given on the command line from the config file
+	if configFlag != "" {
+		if err := loadConfigFile(configFlag); err != nil {
+			printError("Issue loading config file:", err)
+		}
+	}
+
+	au = aurora.NewAurora(colorsEnabled(noColorFlag))
+
+	switch {
+	case veryVerboseFlag:
+		verbosity = 2
+	case verboseFlag:
+		verbosity = 1
+	}
+
 	// Validate options
+	setFlags := explicitFlags()
+	optionCount := countOptions(checkFlag, destroyFlag, pingFlag, dupReportFlag, restoreFlag != "", migrateURLFlag != "", applyFlag != "", rateLimitFlag, deliveriesFlag, activateFlag, deactivateFlag, rawFlag, diffFlag != "", serveFlag != "", createCSVFlag != "")
 	switch {
-	case !(checkFlag || destroyFlag):
-		printError("You must select an option: --c or --d")
-	case checkFlag && destroyFlag:
+	case optionCount == 0:
+		printError("You must select an option: --c, --d, -deactivate, -activate, -ping, -dup-report, -deliveries, -raw, -restore, -create-csv, -diff, -migrate-url, -apply, -rate-limit or -serve")
+	case optionCount > 1:
 		printError("You can only select one option")
-	case (filePath != "") && (repoFlag != ""):
-		printError("You can only specify either a file path or repo")
+	case countOptions(filePath != "", len(repoFlag) > 0, orgFlag != "", orgReposFlag != "", teamFlag != "") > 1:
+		printError("You can only specify one of a file path, repo, org, org repos or team")
+	case teamFlag != "" && !teamRegex.MatchString(teamFlag):
+		printError("Team must be given as org/team-slug:", teamFlag)
+	case hookIDFlag != 0 && (!destroyFlag || len(repoFlag) != 1):
+		printError("-hook-id can only be used with --d and a single -r")
+	case apiVersion != "" && !apiVersionRegex.MatchString(apiVersion):
+		printError("API version must be a date e.g. "+webhookit.DefaultAPIVersion+":", apiVersion)
+	case !containsAnyString(hookSortOrders, []string{sortFlag}):
+		printError("Sort must be one of:", strings.Join(hookSortOrders, ", "))
+	case templateFlag != "" && (outputFlag != "text" || countFlag):
+		printError("-template can only be used with text output")
+	case compactFlag && (outputFlag != "text" || countFlag):
+		printError("-compact can only be used with text output")
+	case compactFlag && templateFlag != "":
+		printError("-compact cannot be used with -template")
+	case outFileFlag != "" && !checkFlag:
+		printError("-out-file can only be used with --c")
+	case appIDFlag != 0 && (appInstallationIDFlag == 0 || appPrivateKeyFlag == ""), appIDFlag == 0 && (appInstallationIDFlag != 0 || appPrivateKeyFlag != ""):
+		printError("-app-id, -app-installation-id and -app-private-key must be used together")
+	case appIDFlag != 0 && providerFlag != "github":
+		printError("GitHub App authentication is only supported by the github provider")
+	case pruneFlag && applyFlag == "":
+		printError("-prune can only be used with -apply")
+	case http.Header(customHeaders).Get("Authorization") != "" && !headerOverrideAuthFlag:
+		printError("-header cannot set Authorization unless -header-override-auth is given")
+	case urlOnlyFlag && urlMatchFlag == "" && urlListFlag == "":
+		printError("-url-only can only be used with -url-match or -url-list")
+	case limitFlag < 0:
+		printError("Limit must not be negative")
+	case confirmLength <= 0:
+		printError("Confirm length must be a positive number")
+	case strings.ContainsAny(knownPassPhrase, " \t\r\n"):
+		printError("-confirm must not contain whitespace")
+	case knownPassPhrase != "" && yesFlag:
+		printError("-confirm cannot be used with -yes")
+	case slowThresholdFlag <= 0:
+		printError("Slow threshold must be positive")
+	case deadlineFlag < 0:
+		printError("Deadline must not be negative")
+	case timeoutFlag <= 0:
+		printError("Timeout must be a positive number of seconds")
+	case repoTimeout < 0:
+		printError("Repo timeout must not be negative")
+	case providerFlag != "github" && providerFlag != "gitlab":
+		printError("Invalid provider:", providerFlag)
+	case providerFlag == "gitlab" && (pingFlag || restoreFlag != "" || createCSVFlag != "" || orgReposFlag != "" || teamFlag != "" || migrateURLFlag != "" || applyFlag != "" || rateLimitFlag || deliveriesFlag || activateFlag || deactivateFlag):
+		printError("-ping, -deliveries, -activate, -deactivate, -restore, -create-csv, -migrate-url, -apply, -rate-limit, -org-repos and -team are only supported by the github provider")
+	case repoFormatFlag != "" && repoFormatFlag != "json" && repoFormatFlag != "text" && repoFormatFlag != "yaml":
+		printError("Invalid repo format:", repoFormatFlag)
+	case outputFlag != "text" && outputFlag != "json" && outputFlag != "ndjson" && outputFlag != "csv":
+		printError("Invalid output format:", outputFlag)
+	case streamFlag && outputFlag == "json":
+		printError("-stream cannot be used with json output")
+	case setFlags["interval"] && serveFlag == "":
+		printError("-interval can only be used with -serve")
+	case intervalFlag <= 0:
+		printError("Interval must be positive")
+	case cacheTTL <= 0:
+		printError("Cache TTL must be positive")
+	case typesExcludeFlag != "" && setFlags["t"]:
+		printError("-types-exclude cannot be used with -t")
+	case strings.ToLower(typesExcludeFlag) == "none":
+		printError("-types-exclude must list HTTP status code types")
+	case typesExcludeFlag != "" && urlOnlyFlag:
+		printError("-types-exclude cannot be used with -url-only")
+	case crossRepoDupsFlag && !duplicatesFlag:
+		printError("-cross-repo-dups can only be used with -ds")
+	case expectEventsFlag != "" && !checkFlag:
+		printError("-expect-events can only be used with --c")
+	case expectEventsFlag != "" && len(splitCSV(expectEventsFlag)) == 0:
+		printError("-expect-events must list at least one event")
+	case dupOnlyFlag && !checkFlag:
+		printError("-dup-only can only be used with --c")
+	case statusFDFlag != 0 && !checkFlag:
+		printError("-status-fd can only be used with --c")
+	case statusFDFlag < 0:
+		printError("Status fd must not be negative")
+	case countFlag && (!checkFlag || streamFlag):
+		printError("-count can only be used with --c and not with -stream")
 	}
 
-	// Retrieve repos from JSON file
-	if repoFlag != "" {
-		reposContainer.Repos = append(reposContainer.Repos, Repo{repoFlag})
-	} else {
-		retrieveRepos(filePath)
+	if err := validateRepoPatterns(includeFlag); err != nil {
+		printError("Invalid -include pattern:", err)
+	}
+	if err := validateRepoPatterns(excludeFlag); err != nil {
+		printError("Invalid -exclude pattern:", err)
+	}
+
+	// Build filter of hooks to consider
+	filter := HookFilter{
+		Events:       splitCSV(eventsFlag),
+		OlderThan:    olderThanFlag,
+		ContentType:  contentTypeFlag,
+		InsecureOnly: insecureOnlyFlag,
+	}
+	showHookAge = olderThanFlag > 0
+	compactOutput = compactFlag
+	if activeFlag != "" {
+		active, err := strconv.ParseBool(activeFlag)
+		if err != nil {
+			printError("Invalid -active value, expected true or false:", activeFlag)
+		}
+		filter.Active = &active
+	}
+	if createdAfterFlag != "" {
+		createdAfter, err := parseDate(createdAfterFlag)
+		if err != nil {
+			printError("Invalid -created-after value:", err)
+		}
+		filter.CreatedAfter = createdAfter
+	}
+
+	destroyOptions := DestroyOptions{
+		Types:               typesFlag,
+		ExcludeTypes:        typesExcludeFlag != "",
+		Duplicates:          duplicatesFlag,
+		CrossRepoDuplicates: crossRepoDupsFlag,
+		Untriggered:         untriggeredFlag,
+		NeverSucceeded:      neverSucceededFlag,
+		ListHooksToDestroy:  listHooksToDestroyFlag,
+		Backup:              backupFlag,
+		DryRun:              dryRunFlag,
+		Yes:                 yesFlag,
+		URLOnly:             urlOnlyFlag,
+		Limit:               limitFlag,
+		Interactive:         interactiveFlag,
+		AuditLog:            auditLogFlag,
+		Deactivate:          deactivateFlag,
+	}
+	if destroyOptions.ExcludeTypes {
+		destroyOptions.Types = typesExcludeFlag
+	}
+	if urlMatchFlag != "" {
+		urlMatch, err := regexp.Compile(urlMatchFlag)
+		if err != nil {
+			printError("Invalid -url-match regular expression:", err)
+		}
+		destroyOptions.URLMatch = urlMatch
+	}
+	if urlListFlag != "" {
+		urlList, err := readURLList(urlListFlag)
+		if err != nil {
+			printError("Issue reading url list:", err)
+		}
+		destroyOptions.URLList = urlList
+	}
+
+	var migrateOptions MigrateOptions
+	if migrateURLFlag != "" {
+		oldURL, newURL, err := parseMigrateFlag(migrateURLFlag)
+		if err != nil {
+			printError("Invalid -migrate-url:", err)
+		}
+		migrateOptions = MigrateOptions{
+			OldURL: oldURL,
+			NewURL: newURL,
+			DryRun: dryRunFlag,
+			Yes:    yesFlag,
+		}
+	}
+
+	// Keep stdout free for machine-readable results
+	if outputFlag != "text" || countFlag {
+		infoOutput = os.Stderr
+	}
+	showProgress = outputFlag == "text" && !countFlag && isTerminal(os.Stderr)
+
+	if syslogFlag {
+		logger, err := openSyslog(syslogFacilityFlag, syslogTagFlag)
+		if err != nil {
+			printError("Issue opening syslog:", err)
+		}
+		syslogLogger = logger
+		infoOutput = syslogWriter{logger: logger}
+	}
+
+	if templateFlag != "" {
+		tmpl, err := parseHookTemplate(templateFlag)
+		if err != nil {
+			printError("Invalid template:", err)
+		}
+		hookTemplate = tmpl
+	}
+
+	if apiURLFlag != "" {
+		baseURL, err := normalizeAPIURL(apiURLFlag)
+		if err != nil {
+			printError(fmt.Sprintf("Invalid API url %s:", apiURLFlag), err)
+		}
+		apiURLFlag = baseURL
+		// GitHub Enterprise Server serves its API under /api/v3 rather than at the host root
+		if parsedURL, _ := url.Parse(baseURL); providerFlag != "gitlab" && parsedURL.Path == "" && parsedURL.Host != "api.github.com" {
+			fmt.Fprintln(infoOutput, au.Brown(fmt.Sprintf("The API url %s has no path. GitHub Enterprise Server serves its API under %s/api/v3", baseURL, baseURL)))
+		}
+	}
+	client.Timeout = time.Duration(timeoutFlag) * time.Second
+	if err := configureProxy(proxyFlag); err != nil {
+		printError("Invalid proxy:", err)
+	}
+	if err := configureTLS(caCertFlag, insecureSkipVerifyFlag); err != nil {
+		printError("Issue loading CA certificate:", err)
+	}
+	if insecureSkipVerifyFlag {
+		fmt.Fprintln(os.Stderr, au.Bold(au.Red("WARNING: TLS certificate verification is disabled. API requests and the API key can be intercepted.\n")))
+	}
+
+	// Read API key from file if given
+	if tokenFileFlag != "" {
+		token, err := readTokenFile(tokenFileFlag)
+		if err != nil {
+			printError("Issue reading token file:", err)
+		}
+		apiKey = token
+	}
+	apiClient = newAPIClient(newProvider(providerFlag, apiURLFlag))
+
+	// Cancel every request once the deadline passes
+	ctx := context.Background()
+	if deadlineFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadlineFlag)
+		defer cancel()
 	}
 
-	// Check API key exists
-	if !checkAPIKey(apiKey) {
+	// Authenticate as a GitHub App if given, otherwise check API key exists
+	if appIDFlag != 0 {
+		app, err := loadGitHubApp(appIDFlag, appInstallationIDFlag, appPrivateKeyFlag)
+		if err != nil {
+			printError("Issue loading GitHub App private key:", err)
+		}
+		// Fetch the first installation token now so bad credentials fail early
+		if err := app.refresh(ctx); err != nil {
+			printError("Issue authenticating as GitHub App:", err)
+		}
+		apiClient.Authorization = app.authorization
+	} else if !checkAPIKey(apiKey) {
 		printError("API key not found.")
 	}
 
-	// Execute API requests
+	// Retrieve repos from the chosen source. Restores, creates and applies take their repos from
+	// their file and the rate limit report only uses repos if given.
+	if len(repoFlag) > 0 {
+		for _, repoName := range repoFlag {
+			reposContainer.Repos = append(reposContainer.Repos, Repo{Name: repoName})
+		}
+	} else if orgFlag != "" {
+		reposContainer.Repos = append(reposContainer.Repos, Repo{Name: orgFlag, Org: true})
+	} else if orgReposFlag != "" {
+		retrieveOrgRepos(ctx, orgReposFlag, archivedFlag)
+	} else if teamFlag != "" {
+		retrieveTeamRepos(ctx, teamFlag, archivedFlag)
+	} else if filePath != "" {
+		retrieveRepos(filePath, repoFormatFlag)
+	} else if restoreFlag == "" && createCSVFlag == "" && diffFlag == "" && applyFlag == "" && !rateLimitFlag {
+		defaultPath, err := defaultReposFile()
+		if err != nil {
+			printError(err)
+		}
+		logVerbose(1, "Using repos file %s", defaultPath)
+		retrieveRepos(defaultPath, repoFormatFlag)
+	}
+
+	// Remove repos listed more than once
+	var duplicateRepoCount int
+	reposContainer.Repos, duplicateRepoCount = deduplicateRepos(reposContainer.Repos)
+	if duplicateRepoCount > 0 {
+		fmt.Fprintln(infoOutput, au.Brown(fmt.Sprintf("Removed %d duplicate repo(s)\n", duplicateRepoCount)))
+	}
+
+	// Keep only the targeted subset of repos
+	if len(includeFlag) > 0 {
+		var notIncludedRepoCount int
+		reposContainer.Repos, notIncludedRepoCount = includeRepos(reposContainer.Repos, includeFlag)
+		fmt.Fprintln(infoOutput, au.Brown(fmt.Sprintf("Skipped %d repo(s) not matching -include\n", notIncludedRepoCount)))
+	}
+
+	// Remove repos that must not be touched
+	if len(excludeFlag) > 0 {
+		var excludedRepoCount int
+		reposContainer.Repos, excludedRepoCount = excludeRepos(reposContainer.Repos, excludeFlag)
+		fmt.Fprintln(infoOutput, au.Brown(fmt.Sprintf("Excluded %d repo(s)\n", excludedRepoCount)))
+	}
+
+	// Scanning no repos would otherwise be reported as a success
+	if len(reposContainer.Repos) == 0 && restoreFlag == "" && createCSVFlag == "" && diffFlag == "" && applyFlag == "" && !rateLimitFlag {
+		printError("No repositories to scan. Check the repo source and -include and -exclude patterns.")
+	}
+
+	// Changes are only ever made based on fresh webhooks
+	changesHooks := destroyFlag || deactivateFlag || activateFlag || migrateURLFlag != "" || applyFlag != "" || restoreFlag != "" || createCSVFlag != ""
+	apiClient.RefreshCache = noCacheFlag || (changesHooks && !dryRunFlag)
+
+	// Open the status fd before scanning so a bad fd fails fast
+	var statusOutput io.Writer
+	if statusFDFlag != 0 {
+		statusFile := os.NewFile(uintptr(statusFDFlag), "status-fd")
+		if statusFile == nil {
+			printError("Invalid status fd:", statusFDFlag)
+		}
+		if _, err := statusFile.Stat(); err != nil {
+			printError("Invalid status fd:", err)
+		}
+		statusOutput = statusFile
+	}
+
+	// Sorted once so each hook's sorted events can be compared to it
+	var expectEvents []string
+	if expectEventsFlag != "" {
+		expectEvents = sortedCopy(splitCSV(expectEventsFlag))
+	}
+
+	// Execute API requests. Modes other than check return their error to exit with.
+	var modeErr error
 	switch {
 	case checkFlag:
-		executeCheck(backupFlag)
+		err := executeCheck(ctx, CheckOptions{
+			Backup:       backupFlag,
+			Output:       outputFlag,
+			FailOnBroken: failOnBrokenFlag,
+			Quiet:        quietFlag,
+			DupOnly:      dupOnlyFlag,
+			ExpectEvents: expectEvents,
+			Sort:         sortFlag,
+			OutFile:      outFileFlag,
+			Count:        countFlag,
+			Stream:       streamFlag,
+			StatusOutput: statusOutput,
+		}, filter)
+		printNotFoundRepos()
+		if err == errBrokenHooks {
+			os.Exit(exitBrokenHooks)
+		}
+		if err != nil {
+			// Keep stdout free for machine-readable results
+			fmt.Fprintln(infoOutput, au.Red(fmt.Sprint("Check failed: ", err)))
+			os.Exit(1)
+		}
+	case destroyFlag && hookIDFlag != 0:
+		if providerFlag == "github" && !dryRunFlag {
+			checkDestroyScopes(ctx, []Repo{{Name: repoFlag[0]}})
+		}
+		modeErr = executeDestroyHook(ctx, repoFlag[0], hookIDFlag, destroyOptions)
 	case destroyFlag:
-		executeDestroy(typesFlag, duplicatesFlag, untriggeredFlag, listHooksToDestroyFlag, backupFlag)
+		// GitLab reports neither rate limits nor token scopes
+		if providerFlag == "github" {
+			checkRateLimitBudget(ctx)
+			if !dryRunFlag {
+				checkDestroyScopes(ctx, reposContainer.Repos)
+			}
+		}
+		modeErr = executeDestroy(ctx, destroyOptions, filter)
+	case deactivateFlag:
+		if providerFlag == "github" {
+			checkRateLimitBudget(ctx)
+			if !dryRunFlag {
+				checkDestroyScopes(ctx, reposContainer.Repos)
+			}
+		}
+		modeErr = executeDestroy(ctx, destroyOptions, filter)
+	case pingFlag:
+		modeErr = executePing(ctx, filter)
+	case dupReportFlag:
+		modeErr = executeDuplicateReport(ctx, filter)
+	case deliveriesFlag:
+		modeErr = executeDeliveries(ctx, slowThresholdFlag, filter)
+	case rawFlag:
+		modeErr = executeRaw(ctx)
+	case serveFlag != "":
+		showProgress = false
+		modeErr = executeServe(ctx, serveFlag, intervalFlag, filter)
+	case restoreFlag != "":
+		modeErr = executeRestore(ctx, restoreFlag)
+	case createCSVFlag != "":
+		modeErr = executeCreateCSV(ctx, createCSVFlag, dryRunFlag)
+	case diffFlag != "":
+		modeErr = executeDiff(ctx, diffFlag)
+	case migrateURLFlag != "":
+		modeErr = executeMigrate(ctx, migrateOptions, filter)
+	case activateFlag:
+		modeErr = executeActivate(ctx, ActivateOptions{
+			URLMatch: destroyOptions.URLMatch,
+			DryRun:   dryRunFlag,
+			Yes:      yesFlag,
+		}, filter)
+	case rateLimitFlag:
+		modeErr = executeRateLimit(ctx)
+	case applyFlag != "":
+		modeErr = executeApply(ctx, ApplyOptions{
+			SpecPath: applyFlag,
+			Prune:    pruneFlag,
+			DryRun:   dryRunFlag,
+			Yes:      yesFlag,
+		})
+	}
+
+	printNotFoundRepos()
+	logVerbose(1, "%s", apiStats.ToString())
+	if modeErr != nil {
+		printError(modeErr)
 	}
 }
@@ -0,0 +1,920 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+	"github.com/logrusorgru/aurora"
+)
+
+// mockGitHub is a fake GitHub API serving canned webhooks of repos, a page at a
+// time, and recording the requests made to it
+type mockGitHub struct {
+	*httptest.Server
+	mutex sync.Mutex
+	// hooks are the webhooks of each repo by name e.g. owner/repo
+	hooks map[string][]WebHook
+	// pageSize is the number of webhooks served per page regardless of per_page
+	pageSize int
+	// failDeletes maps hook IDs to the status code their DELETE responds with
+	failDeletes map[int]int
+	// requests are the method and path of every request e.g. GET /repos/owner/repo/hooks?page=2
+	requests []string
+	// deleted are the IDs of the webhooks deleted
+	deleted []int
+	// scopes is the X-OAuth-Scopes header of every response, not sent if nil
+	scopes *string
+	// admins are the repos the API key has admin access to
+	admins map[string]bool
+	// created are the hooks path and config URL of the webhooks created e.g. /orgs/org/hooks https://example.com
+	created []string
+}
+
+// newMockGitHub starts a fake GitHub API and points every API request at it,
+// as -api-url does. Globals changed for the test are restored when it ends.
+// @arg t *testing.T
+// @arg hooks map[string][]WebHook - Webhooks of each repo
+// @return *mockGitHub
+func newMockGitHub(t *testing.T, hooks map[string][]WebHook) *mockGitHub {
+	t.Helper()
+	mock := &mockGitHub{hooks: hooks, pageSize: 2, failDeletes: make(map[int]int)}
+	mock.Server = httptest.NewServer(http.HandlerFunc(mock.serveHTTP))
+	t.Cleanup(mock.Close)
+
+	oldClient, oldAPIKey, oldDelay, oldRetries, oldAu, oldRepos := apiClient, apiKey, requestDelay, maxRetries, au, reposContainer
+	t.Cleanup(func() {
+		apiClient, apiKey, requestDelay, maxRetries, au, reposContainer = oldClient, oldAPIKey, oldDelay, oldRetries, oldAu, oldRepos
+	})
+	apiKey = "test-token"
+	requestDelay = 0
+	maxRetries = 0
+	apiClient = newAPIClient(webhookit.GitHubProvider{BaseURL: mock.URL})
+	au = aurora.NewAurora(false)
+	reposContainer = ReposContainer{}
+	for name := range hooks {
+		reposContainer.Repos = append(reposContainer.Repos, Repo{Name: name})
+	}
+	sort.Slice(reposContainer.Repos, func(i, j int) bool {
+		return reposContainer.Repos[i].Name < reposContainer.Repos[j].Name
+	})
+	return mock
+}
+
+func (m *mockGitHub) serveHTTP(writer http.ResponseWriter, request *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests = append(m.requests, request.Method+" "+request.URL.RequestURI())
+
+	if request.Header.Get("Authorization") != "token test-token" {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if m.scopes != nil {
+		writer.Header().Set("X-OAuth-Scopes", *m.scopes)
+	}
+	if request.URL.Path == "/rate_limit" {
+		writer.Write([]byte(`{}`))
+		return
+	}
+
+	// Org hooks are keyed by the name of the org alone
+	var repoName string
+	var parts []string
+	switch {
+	case strings.HasPrefix(request.URL.Path, "/repos/"):
+		parts = strings.Split(strings.TrimPrefix(request.URL.Path, "/repos/"), "/")
+		if len(parts) >= 2 {
+			repoName, parts = parts[0]+"/"+parts[1], parts[2:]
+		}
+	case strings.HasPrefix(request.URL.Path, "/orgs/"):
+		parts = strings.Split(strings.TrimPrefix(request.URL.Path, "/orgs/"), "/")
+		repoName, parts = parts[0], parts[1:]
+	}
+	hooks, ok := m.hooks[repoName]
+	if ok && len(parts) == 0 && request.Method == "GET" {
+		fmt.Fprintf(writer, `{"full_name": %q, "permissions": {"admin": %t}}`, repoName, m.admins[repoName])
+		return
+	}
+	if !ok || len(parts) == 0 || parts[0] != "hooks" {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	hooksPath := request.URL.Path[:strings.Index(request.URL.Path, "/hooks")+len("/hooks")]
+
+	switch {
+	case request.Method == "GET" && len(parts) == 1:
+		page, _ := strconv.Atoi(request.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		start := (page - 1) * m.pageSize
+		end := start + m.pageSize
+		if start > len(hooks) {
+			start = len(hooks)
+		}
+		if end >= len(hooks) {
+			end = len(hooks)
+		} else {
+			next := *request.URL
+			query := next.Query()
+			query.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = query.Encode()
+			writer.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next", <%s%s?page=1>; rel="first"`, m.URL, next.RequestURI(), m.URL, hooksPath))
+		}
+		pageHooks := make([]WebHook, 0, end-start)
+		for _, hook := range hooks[start:end] {
+			hook.URL = fmt.Sprintf("%s%s/%d", m.URL, hooksPath, hook.ID)
+			hook.PingURL = hook.URL + "/pings"
+			pageHooks = append(pageHooks, hook)
+		}
+		json.NewEncoder(writer).Encode(pageHooks)
+	case request.Method == "POST" && len(parts) == 1:
+		var hookRequest HookRequest
+		if err := json.NewDecoder(request.Body).Decode(&hookRequest); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		hook := WebHook{ID: 1000 + len(m.created), Name: hookRequest.Name, Active: hookRequest.Active, Events: hookRequest.Events}
+		hook.Config.URL = hookRequest.Config.URL
+		m.hooks[repoName] = append(hooks, hook)
+		m.created = append(m.created, hooksPath+" "+hook.Config.URL)
+		writer.WriteHeader(http.StatusCreated)
+		json.NewEncoder(writer).Encode(hook)
+	case request.Method == "POST" && len(parts) == 3 && parts[2] == "pings":
+		// A delivered ping succeeds
+		id, _ := strconv.Atoi(parts[1])
+		for i := range hooks {
+			if hooks[i].ID == id {
+				hooks[i].LastResponse.Code, hooks[i].LastResponse.Message = 200, "OK"
+				writer.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		writer.WriteHeader(http.StatusNotFound)
+	case request.Method == "PATCH" && len(parts) == 3 && parts[2] == "config":
+		var config map[string]string
+		if err := json.NewDecoder(request.Body).Decode(&config); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		id, _ := strconv.Atoi(parts[1])
+		for i := range hooks {
+			if hooks[i].ID == id {
+				hooks[i].Config.URL = config["url"]
+				json.NewEncoder(writer).Encode(hooks[i].Config)
+				return
+			}
+		}
+		writer.WriteHeader(http.StatusNotFound)
+	case request.Method == "DELETE" && len(parts) == 2:
+		id, _ := strconv.Atoi(parts[1])
+		if status, ok := m.failDeletes[id]; ok {
+			writer.WriteHeader(status)
+			return
+		}
+		m.deleted = append(m.deleted, id)
+		for i := range hooks {
+			if hooks[i].ID == id {
+				m.hooks[repoName] = append(hooks[:i:i], hooks[i+1:]...)
+				break
+			}
+		}
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// requestsMatching returns the recorded requests starting with a prefix
+// @arg prefix string - e.g. GET /repos/owner/repo/hooks
+// @return []string
+func (m *mockGitHub) requestsMatching(prefix string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var matching []string
+	for _, request := range m.requests {
+		if strings.HasPrefix(request, prefix) {
+			matching = append(matching, request)
+		}
+	}
+	return matching
+}
+
+// deletedIDs returns the sorted IDs of the webhooks deleted
+// @return []int
+func (m *mockGitHub) deletedIDs() []int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ids := append([]int{}, m.deleted...)
+	sort.Ints(ids)
+	return ids
+}
+
+func TestGetWebHooksFollowsPagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		hookCount int
+		pages     int
+	}{
+		{"no hooks", 0, 1},
+		{"single page", 2, 1},
+		{"partial last page", 5, 3},
+		{"full last page", 6, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var hooks []WebHook
+			var wantIDs = []int{}
+			for id := 1; id <= test.hookCount; id++ {
+				hooks = append(hooks, webhookit.TestHook(id, fmt.Sprintf("https://example.com/%d", id), 200))
+				wantIDs = append(wantIDs, id)
+			}
+			mock := newMockGitHub(t, map[string][]WebHook{"owner/repo": hooks})
+
+			webHooks, err := getWebHooks(context.Background(), Repo{Name: "owner/repo"})
+			if err != nil {
+				t.Fatalf("getWebHooks returned error: %v", err)
+			}
+			if got := webhookit.HookIDs(webHooks.Hooks); !reflect.DeepEqual(got, wantIDs) {
+				t.Errorf("hook IDs = %v, want %v", got, wantIDs)
+			}
+			for _, hook := range webHooks.Hooks {
+				if hook.Repo != "owner/repo" {
+					t.Errorf("hook %d Repo = %q, want owner/repo", hook.ID, hook.Repo)
+				}
+			}
+
+			requests := mock.requestsMatching("GET /repos/owner/repo/hooks")
+			if len(requests) != test.pages {
+				t.Fatalf("made %d requests, want %d: %v", len(requests), test.pages, requests)
+			}
+			if !strings.Contains(requests[0], "per_page=100") {
+				t.Errorf("first request %q does not ask for 100 hooks per page", requests[0])
+			}
+		})
+	}
+}
+
+func TestGetWebHooksErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		repo    string
+		apiKey  string
+		wantErr string
+	}{
+		{"missing repo", "owner/missing", "test-token", "Repository not found or no access: owner/missing"},
+		{"bad token", "owner/repo", "wrong-token", "Authentication failed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newMockGitHub(t, map[string][]WebHook{"owner/repo": {webhookit.TestHook(1, "https://example.com", 200)}})
+			apiClient.Token = test.apiKey
+
+			_, err := getWebHooks(context.Background(), Repo{Name: test.repo})
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("getWebHooks error = %v, want one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteDestroyMatchesTypes(t *testing.T) {
+	hooks := []WebHook{
+		webhookit.TestHook(1, "https://example.com/ok", 200),
+		webhookit.TestHook(2, "https://example.com/redirect", 301),
+		webhookit.TestHook(3, "https://example.com/missing", 404),
+		webhookit.TestHook(4, "https://example.com/gone", 410),
+		webhookit.TestHook(5, "https://example.com/error", 500),
+		webhookit.TestHook(6, "https://example.com/unavailable", 503),
+		webhookit.TestHook(7, "https://example.com/never", 0),
+	}
+	tests := []struct {
+		types string
+		want  []int
+	}{
+		{"3XX", []int{2}},
+		{"4XX", []int{3, 4}},
+		{"5XX", []int{5, 6}},
+		{"3XX,4XX,5XX", []int{2, 3, 4, 5, 6}},
+		{"404", []int{3}},
+		{"40X,503", []int{3, 6}},
+		{"400-410", []int{3, 4}},
+		{"none", []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.types, func(t *testing.T) {
+			mock := newMockGitHub(t, map[string][]WebHook{"owner/repo": hooks})
+
+			err := executeDestroy(context.Background(), DestroyOptions{Types: test.types, Yes: true}, HookFilter{})
+			if err != nil {
+				t.Fatalf("executeDestroy returned error: %v", err)
+			}
+			if got := mock.deletedIDs(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("deleted %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecuteDestroySendsDeleteToEachHook(t *testing.T) {
+	mock := newMockGitHub(t, map[string][]WebHook{
+		"owner/one": {webhookit.TestHook(1, "https://example.com/a", 500), webhookit.TestHook(2, "https://example.com/b", 200), webhookit.TestHook(3, "https://example.com/c", 502)},
+		"owner/two": {webhookit.TestHook(4, "https://example.com/d", 404)},
+	})
+
+	if err := executeDestroy(context.Background(), DestroyOptions{Types: "4XX,5XX", Yes: true}, HookFilter{}); err != nil {
+		t.Fatalf("executeDestroy returned error: %v", err)
+	}
+
+	want := []string{"DELETE /repos/owner/one/hooks/1", "DELETE /repos/owner/one/hooks/3", "DELETE /repos/owner/two/hooks/4"}
+	got := mock.requestsMatching("DELETE ")
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DELETE requests = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteDestroyReturnsFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		failDelete  bool
+		missingRepo bool
+	}{
+		{name: "failed delete", failDelete: true},
+		{name: "failed repo", missingRepo: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := newMockGitHub(t, map[string][]WebHook{
+				"owner/repo": {webhookit.TestHook(1, "https://example.com/a", 500), webhookit.TestHook(2, "https://example.com/b", 404)},
+			})
+			if test.failDelete {
+				mock.failDeletes[1] = http.StatusInternalServerError
+			}
+			if test.missingRepo {
+				reposContainer.Repos = append(reposContainer.Repos, Repo{Name: "owner/missing"})
+			}
+
+			if err := executeDestroy(context.Background(), DestroyOptions{Types: "4XX,5XX", Yes: true}, HookFilter{}); err == nil {
+				t.Error("executeDestroy returned nil, want an error")
+			}
+			// The failure does not stop the other hooks being destroyed
+			if got := len(mock.requestsMatching("DELETE ")); got != 2 {
+				t.Errorf("sent %d DELETE request(s), want 2", got)
+			}
+		})
+	}
+}
+
+func TestExecuteDestroyDryRunSendsNoDelete(t *testing.T) {
+	mock := newMockGitHub(t, map[string][]WebHook{"owner/repo": {webhookit.TestHook(1, "https://example.com", 500)}})
+
+	if err := executeDestroy(context.Background(), DestroyOptions{Types: "5XX", DryRun: true}, HookFilter{}); err != nil {
+		t.Fatalf("executeDestroy returned error: %v", err)
+	}
+	if got := mock.requestsMatching("DELETE "); len(got) != 0 {
+		t.Errorf("dry run sent DELETE requests: %v", got)
+	}
+}
+
+func TestExecuteCheckReportsEveryPage(t *testing.T) {
+	newMockGitHub(t, map[string][]WebHook{
+		"owner/repo": {
+			webhookit.TestHook(1, "https://example.com/a", 200),
+			webhookit.TestHook(2, "https://example.com/b", 404),
+			webhookit.TestHook(3, "https://example.com/c", 0),
+			webhookit.TestHook(4, "https://example.com/a/", 500),
+			webhookit.TestHook(5, "https://example.com/e", 201),
+		},
+	})
+
+	var output bytes.Buffer
+	var summaries = make(map[string]CheckSummary)
+	err := executeCheck(context.Background(), CheckOptions{Output: "json", Sort: "id", ResultsOutput: &output, RepoSummaries: summaries, FailOnBroken: true}, HookFilter{})
+	if err != errBrokenHooks {
+		t.Errorf("executeCheck error = %v, want %v", err, errBrokenHooks)
+	}
+
+	var results []CheckResult
+	if err := json.Unmarshal(output.Bytes(), &results); err != nil {
+		t.Fatalf("could not decode results %q: %v", output.String(), err)
+	}
+	var gotIDs []int
+	for _, result := range results {
+		gotIDs = append(gotIDs, result.HookID)
+	}
+	if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(gotIDs, want) {
+		t.Errorf("checked hook IDs = %v, want %v", gotIDs, want)
+	}
+
+	want := CheckSummary{Repos: 1, Hooks: 5, Healthy: 2, Broken: 2, NeverTriggered: 1, Duplicates: 2, DuplicateGroups: 1}
+	if got := summaries["owner/repo"]; got != want {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+}
+
+func TestExecuteCheckReturnsFailedRepos(t *testing.T) {
+	newMockGitHub(t, map[string][]WebHook{"owner/repo": {webhookit.TestHook(1, "https://example.com", 200)}})
+	reposContainer.Repos = append(reposContainer.Repos, Repo{Name: "owner/missing"})
+
+	err := executeCheck(context.Background(), CheckOptions{Output: "json", Sort: "id", ResultsOutput: &bytes.Buffer{}}, HookFilter{})
+	checkErr, ok := err.(*CheckError)
+	if !ok {
+		t.Fatalf("executeCheck error = %v, want a *CheckError", err)
+	}
+	if _, ok := checkErr.FailedRepos["owner/missing"]; !ok || len(checkErr.FailedRepos) != 1 {
+		t.Errorf("failed repos = %v, want only owner/missing", checkErr.FailedRepos)
+	}
+}
+
+func TestExecuteRestore(t *testing.T) {
+	mock := newMockGitHub(t, map[string][]WebHook{
+		"owner/repo": {webhookit.TestHook(1, "https://Example.com/existing/", 200)},
+		"org":        {},
+	})
+	existing := webhookit.TestHook(1, "https://example.com/existing", 200)
+	existing.Repo = "owner/repo"
+	missing := webhookit.TestHook(2, "https://example.com/missing", 200)
+	missing.Repo = "owner/repo"
+	orgHook := webhookit.TestHook(3, "https://example.com/org", 200)
+	orgHook.Repo, orgHook.Org = "org", true
+
+	backupPath := filepath.Join(t.TempDir(), "backup.json")
+	backupJSON, err := json.Marshal(Backup{SchemaVersion: backupSchemaVersion, Hooks: []WebHook{existing, missing, orgHook}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(backupPath, backupJSON, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := executeRestore(context.Background(), backupPath); err != nil {
+		t.Fatalf("executeRestore = %v", err)
+	}
+	want := []string{"/repos/owner/repo/hooks https://example.com/missing", "/orgs/org/hooks https://example.com/org"}
+	if !reflect.DeepEqual(mock.created, want) {
+		t.Errorf("created %v, want %v", mock.created, want)
+	}
+}
+
+func TestGroupDuplicatesFlagsEveryHookSharingAURL(t *testing.T) {
+	var hooks WebHooks
+	for id, configURL := range []string{"https://example.com/a", "https://example.com/shared", "https://example.com/shared", "https://example.com/shared"} {
+		hook := webhookit.TestHook(id+1, configURL, 200)
+		hook.URL = fmt.Sprintf("https://api.github.com/repos/owner/repo/hooks/%d", id+1)
+		hooks.Hooks = append(hooks.Hooks, hook)
+	}
+
+	// Map iteration order varies between runs so group repeatedly
+	for run := 0; run < 20; run++ {
+		hooksMap := wrapWebHooks(hooks)
+		groups := groupDuplicates(hooksMap)
+		if len(groups) != 1 || len(groups[0]) != 3 {
+			t.Fatalf("run %d: got %d group(s), want one group of 3", run, len(groups))
+		}
+		for i, hook := range groups[0] {
+			if hook.Hook.ID != i+2 {
+				t.Errorf("run %d: group[%d] is hook %d, want %d", run, i, hook.Hook.ID, i+2)
+			}
+		}
+		for _, hook := range hooksMap {
+			if want := hook.Hook.ID != 1; hook.Duplicate != want {
+				t.Errorf("run %d: hook %d duplicate = %t, want %t", run, hook.Hook.ID, hook.Duplicate, want)
+			}
+		}
+	}
+}
+
+func TestFieldDiffSkipsBookkeepingFields(t *testing.T) {
+	hooks := []WebHook{webhookit.TestHook(1, "https://example.com/a", 200), webhookit.TestHook(2, "https://example.com/a", 200)}
+	hooks[0].Repo, hooks[0].Org, hooks[0].NoStatus = "owner", true, true
+	oldAu := au
+	t.Cleanup(func() { au = oldAu })
+	au = aurora.NewAurora(false)
+
+	var names []string
+	for _, output := range fieldDiff(hooks, nil) {
+		names = append(names, strings.TrimSpace(strings.SplitN(output, "\n", 2)[0]))
+	}
+	for _, skipped := range []string{"Repo", "Org", "NoStatus", "NoSecretStatus"} {
+		if containsAnyString(names, []string{skipped}) {
+			t.Errorf("fieldDiff listed %s, want it skipped", skipped)
+		}
+	}
+	if !containsAnyString(names, []string{"Config"}) {
+		t.Errorf("fieldDiff listed %v, want Config included", names)
+	}
+}
+
+// captureStdout returns what fn prints to stdout
+// @arg t *testing.T
+// @arg fn func()
+// @return string
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		var buffer bytes.Buffer
+		buffer.ReadFrom(reader)
+		output <- buffer.String()
+	}()
+	fn()
+	writer.Close()
+	return <-output
+}
+
+func TestExecutePingPrintsRefreshedStatus(t *testing.T) {
+	mock := newMockGitHub(t, map[string][]WebHook{"owner/repo": {webhookit.TestHook(1, "https://example.com/hook", 500)}})
+	oldDelay := pingSettleDelay
+	t.Cleanup(func() { pingSettleDelay = oldDelay })
+	pingSettleDelay = 0
+
+	var err error
+	output := captureStdout(t, func() {
+		err = executePing(context.Background(), HookFilter{})
+	})
+	if err != nil {
+		t.Fatalf("executePing = %v", err)
+	}
+	if got := mock.requestsMatching("GET /repos/owner/repo/hooks"); len(got) != 2 {
+		t.Errorf("hooks fetched %d time(s), want 2 (before and after the ping): %v", len(got), got)
+	}
+	if want := "https://example.com/hook => 200 | OK"; !strings.Contains(output, want) {
+		t.Errorf("output does not contain %q:\n%s", want, output)
+	}
+}
+
+func TestConfigValueToString(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"string", `"4XX"`, "4XX"},
+		{"bool", `true`, "true"},
+		{"large number", `12345678`, "12345678"},
+		{"fraction", `0.5`, "0.5"},
+		{"array", `["4XX", 500, 12345678]`, "4XX,500,12345678"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoder := json.NewDecoder(strings.NewReader(test.json))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				t.Fatal(err)
+			}
+			if got := configValueToString(value); got != test.want {
+				t.Errorf("configValueToString(%s) = %q, want %q", test.json, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDestroyWebHooksContinuesAfterAFailure(t *testing.T) {
+	var hooks []WebHook
+	for id := 1; id <= 4; id++ {
+		hooks = append(hooks, webhookit.TestHook(id, fmt.Sprintf("https://example.com/%d", id), 500))
+	}
+	mock := newMockGitHub(t, map[string][]WebHook{"owner/repo": hooks})
+	mock.failDeletes[2] = http.StatusInternalServerError
+	for i := range hooks {
+		hooks[i].URL = fmt.Sprintf("%s/repos/owner/repo/hooks/%d", mock.URL, hooks[i].ID)
+	}
+
+	err := destroyWebHooks(context.Background(), hooks, nil)
+	if err == nil || !strings.Contains(err.Error(), hooks[1].URL) {
+		t.Errorf("destroyWebHooks error = %v, want an error naming %s", err, hooks[1].URL)
+	}
+	if got := len(mock.requestsMatching("DELETE ")); got != 4 {
+		t.Errorf("sent %d DELETE request(s), want 4", got)
+	}
+	if got, want := mock.deletedIDs(), []int{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+}
+
+func TestDeduplicateReposFromFile(t *testing.T) {
+	oldRepos := reposContainer
+	t.Cleanup(func() { reposContainer = oldRepos })
+	reposContainer = ReposContainer{}
+
+	reposPath := filepath.Join(t.TempDir(), "repos.json")
+	reposJSON := `{"repos": [{"name": "owner/repo"}, {"name": "owner/other"}, {"name": "owner/repo"}, {"name": "owner/repo"}]}`
+	if err := os.WriteFile(reposPath, []byte(reposJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+	retrieveRepos(reposPath, "json")
+
+	repos, removed := deduplicateRepos(reposContainer.Repos)
+	if removed != 2 {
+		t.Errorf("removed %d duplicate(s), want 2", removed)
+	}
+	if want := []Repo{{Name: "owner/repo"}, {Name: "owner/other"}}; !reflect.DeepEqual(repos, want) {
+		t.Errorf("repos = %v, want %v", repos, want)
+	}
+}
+
+func TestHookStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        int
+		wantCode    string
+		wantMessage string
+	}{
+		{"never triggered", 0, "0", "Webhook has never been triggered"},
+		{"ok", 200, "200", "OK"},
+		{"not found", 404, "404", "Not Found"},
+		{"server error", 500, "500", "Internal Server Error"},
+		{"single digit", 7, "7", "Unknown status"},
+		{"negative", -1, "-1", "Unknown status"},
+		{"out of range", 999, "999", "Unknown status"},
+	}
+	oldAu := au
+	t.Cleanup(func() { au = oldAu })
+	au = aurora.NewAurora(false)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := webhookit.TestHook(1, "https://example.com", test.code)
+			code, message, _ := hookStatus(hook)
+			if code != test.wantCode || message != test.wantMessage {
+				t.Errorf("hookStatus(%d) = %q, %q, want %q, %q", test.code, code, message, test.wantCode, test.wantMessage)
+			}
+			if got, want := statusToString(hook), "https://example.com => "+test.wantCode+" | "+test.wantMessage; got != want {
+				t.Errorf("statusToString(%d) = %q, want %q", test.code, got, want)
+			}
+		})
+	}
+}
+
+func TestGeneratePassPhraseCoversAToZ(t *testing.T) {
+	if got := len(generatePassPhrase(12)); got != 12 {
+		t.Errorf("passphrase length = %d, want 12", got)
+	}
+
+	// 26 letters drawn 5000 times all appear with overwhelming probability
+	seen := make(map[rune]bool)
+	for _, letter := range generatePassPhrase(5000) {
+		if letter < 'A' || letter > 'Z' {
+			t.Fatalf("passphrase contains %q, want only A to Z", letter)
+		}
+		seen[letter] = true
+	}
+	for letter := 'A'; letter <= 'Z'; letter++ {
+		if !seen[letter] {
+			t.Errorf("%q never generated", letter)
+		}
+	}
+}
+
+func TestDestroyScopesError(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  *string
+		admins  map[string]bool
+		wantErr bool
+	}{
+		{name: "repo scope", scopes: stringPointer("repo, read:org")},
+		{name: "hook scope", scopes: stringPointer("admin:repo_hook")},
+		{name: "read only scopes", scopes: stringPointer("read:org"), wantErr: true},
+		{name: "fine-grained admin", admins: map[string]bool{"owner/one": true, "owner/two": true}},
+		{name: "fine-grained without admin", admins: map[string]bool{"owner/one": true}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := newMockGitHub(t, map[string][]WebHook{"owner/one": {}, "owner/two": {}})
+			mock.scopes, mock.admins = test.scopes, test.admins
+
+			err := destroyScopesError(context.Background(), reposContainer.Repos)
+			if (err != nil) != test.wantErr {
+				t.Errorf("destroyScopesError = %v, want error %t", err, test.wantErr)
+			}
+			if test.wantErr && test.scopes == nil && !strings.Contains(fmt.Sprint(err), "owner/two") {
+				t.Errorf("destroyScopesError = %v, want it to name owner/two", err)
+			}
+		})
+	}
+}
+
+// stringPointer returns a pointer to a copy of s
+// @arg s string
+// @return *string
+func stringPointer(s string) *string {
+	return &s
+}
+
+func TestModesReturnFailures(t *testing.T) {
+	modes := map[string]func(ctx context.Context) error{
+		"destroy": func(ctx context.Context) error {
+			return executeDestroy(ctx, DestroyOptions{Types: "4XX,5XX", Yes: true}, HookFilter{})
+		},
+		"ping":             func(ctx context.Context) error { return executePing(ctx, HookFilter{}) },
+		"duplicate report": func(ctx context.Context) error { return executeDuplicateReport(ctx, HookFilter{}) },
+		"deliveries":       func(ctx context.Context) error { return executeDeliveries(ctx, time.Second, HookFilter{}) },
+		"raw":              func(ctx context.Context) error { return executeRaw(ctx) },
+		"activate":         func(ctx context.Context) error { return executeActivate(ctx, ActivateOptions{Yes: true}, HookFilter{}) },
+		"migrate": func(ctx context.Context) error {
+			return executeMigrate(ctx, MigrateOptions{OldURL: "https://example.com/old", NewURL: "https://example.com/new", Yes: true}, HookFilter{})
+		},
+	}
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			newMockGitHub(t, map[string][]WebHook{"owner/repo": {}})
+			reposContainer.Repos = append(reposContainer.Repos, Repo{Name: "owner/missing"})
+			captureStdout(t, func() {
+				if err := mode(context.Background()); err == nil {
+					t.Error("returned nil for a repo that could not be retrieved, want an error")
+				}
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			captureStdout(t, func() {
+				if err := mode(ctx); !errors.Is(err, context.Canceled) {
+					t.Errorf("returned %v once the deadline was reached, want %v", err, context.Canceled)
+				}
+			})
+		})
+	}
+}
+
+func TestNormalizeAPIURL(t *testing.T) {
+	tests := []struct {
+		rawURL  string
+		want    string
+		wantErr bool
+	}{
+		{rawURL: "https://api.github.com", want: "https://api.github.com"},
+		{rawURL: "https://github.example.com/api/v3/", want: "https://github.example.com/api/v3"},
+		{rawURL: "https://github.example.com/api/v3//", want: "https://github.example.com/api/v3"},
+		{rawURL: "github.example.com/api/v3", wantErr: true},
+		{rawURL: "https://github.example.com/api/v3?a=1", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.rawURL, func(t *testing.T) {
+			got, err := normalizeAPIURL(test.rawURL)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("normalizeAPIURL(%q) error = %v, want error %t", test.rawURL, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("normalizeAPIURL(%q) = %q, want %q", test.rawURL, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAPIURLPathPrefix(t *testing.T) {
+	mock := newMockGitHub(t, map[string][]WebHook{"owner/repo": {webhookit.TestHook(1, "https://example.com", 500)}})
+	// Requests missing the prefix are not found, as on GitHub Enterprise Server
+	enterprise := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(mock.serveHTTP)))
+	t.Cleanup(enterprise.Close)
+	baseURL, err := normalizeAPIURL(enterprise.URL + "/api/v3/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiClient = newAPIClient(newProvider("github", baseURL))
+
+	webHooks, err := getWebHooks(context.Background(), Repo{Name: "owner/repo"})
+	if err != nil {
+		t.Fatalf("getWebHooks returned error: %v", err)
+	}
+	if len(webHooks.Hooks) != 1 {
+		t.Fatalf("got %d hook(s), want 1", len(webHooks.Hooks))
+	}
+	// GitHub returns the URL of each hook in full, which the mock builds without the prefix
+	hook := webHooks.Hooks[0]
+	hook.URL = baseURL + "/repos/owner/repo/hooks/1"
+	if err := apiClient.DeleteWebHook(context.Background(), hook); err != nil {
+		t.Fatalf("DeleteWebHook returned error: %v", err)
+	}
+	if want := []string{"GET /repos/owner/repo/hooks?per_page=100", "DELETE /repos/owner/repo/hooks/1"}; !reflect.DeepEqual(mock.requests, want) {
+		t.Errorf("requests = %v, want %v", mock.requests, want)
+	}
+}
+
+func TestExecuteMigrateMatchesNormalizedURLs(t *testing.T) {
+	mock := newMockGitHub(t, map[string][]WebHook{"owner/repo": {
+		webhookit.TestHook(1, "https://old.example.com/hook", 200),
+		webhookit.TestHook(2, "https://old.example.com/hook/", 200),
+		webhookit.TestHook(3, "https://OLD.example.com/hook", 200),
+		webhookit.TestHook(4, "https://old.example.com/other", 200),
+	}})
+
+	captureStdout(t, func() {
+		if err := executeMigrate(context.Background(), MigrateOptions{OldURL: "https://old.example.com/hook", NewURL: "https://new.example.com/hook", Yes: true}, HookFilter{}); err != nil {
+			t.Errorf("executeMigrate returned error: %v", err)
+		}
+	})
+
+	var got []string
+	for _, hook := range mock.hooks["owner/repo"] {
+		got = append(got, hook.Config.URL)
+	}
+	want := []string{"https://new.example.com/hook", "https://new.example.com/hook", "https://new.example.com/hook", "https://old.example.com/other"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config urls = %v, want %v", got, want)
+	}
+}
+
+func TestPlanRepoMatchesNormalizedURLs(t *testing.T) {
+	existing := []WebHook{
+		webhookit.TestHook(1, "https://Example.com/hook/", 200),
+		webhookit.TestHook(2, "https://example.com/other", 200),
+	}
+	hookSpecs := []HookSpec{
+		{URL: "https://example.com/hook", Events: []string{"push"}, ContentType: "json"},
+		{URL: "https://EXAMPLE.com/other/", Events: []string{"push"}, ContentType: "json"},
+	}
+
+	for _, prune := range []bool{false, true} {
+		if changes := planRepo("owner/repo", existing, hookSpecs, prune); len(changes) != 0 {
+			t.Errorf("planRepo(prune %t) = %v, want no changes", prune, changes)
+		}
+	}
+}
+
+func TestGitHubAppRefreshFailureFailsRequest(t *testing.T) {
+	newMockGitHub(t, map[string][]WebHook{"owner/repo": {}})
+	privateKey, err := rsa.GenerateKey(cryptorand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The token has expired and the mock rejects the refresh
+	app := &GitHubApp{AppID: 1, InstallationID: 2, PrivateKey: privateKey, token: "expired", expiresAt: time.Now()}
+	apiClient.Authorization = app.authorization
+
+	_, err = getWebHooks(context.Background(), Repo{Name: "owner/repo"})
+	if err == nil || !strings.Contains(err.Error(), "refreshing GitHub App installation token") {
+		t.Errorf("getWebHooks error = %v, want the refresh failure", err)
+	}
+}
+
+func TestCachedCheckAfterDestroy(t *testing.T) {
+	mock := newMockGitHub(t, map[string][]WebHook{"owner/repo": {webhookit.TestHook(1, "https://example.com/a", 500), webhookit.TestHook(2, "https://example.com/b", 200)}})
+	oldCacheDir := cacheDir
+	t.Cleanup(func() { cacheDir = oldCacheDir })
+	cacheDir = t.TempDir()
+	apiClient.Cache = dirCache{}
+
+	checkedIDs := func() []int {
+		var output bytes.Buffer
+		if err := executeCheck(context.Background(), CheckOptions{Output: "json", Sort: "id", ResultsOutput: &output}, HookFilter{}); err != nil {
+			t.Fatalf("executeCheck returned error: %v", err)
+		}
+		var results []CheckResult
+		if err := json.Unmarshal(output.Bytes(), &results); err != nil {
+			t.Fatalf("could not decode results %q: %v", output.String(), err)
+		}
+		ids := []int{}
+		for _, result := range results {
+			ids = append(ids, result.HookID)
+		}
+		return ids
+	}
+
+	captureStdout(t, func() {
+		if got := checkedIDs(); !reflect.DeepEqual(got, []int{1, 2}) {
+			t.Fatalf("checked hook IDs = %v, want [1 2]", got)
+		}
+
+		// Destroys always fetch fresh webhooks, caching them before the delete
+		apiClient.RefreshCache = true
+		if err := executeDestroy(context.Background(), DestroyOptions{Types: "5XX", Yes: true}, HookFilter{}); err != nil {
+			t.Fatalf("executeDestroy returned error: %v", err)
+		}
+		apiClient.RefreshCache = false
+
+		if got := checkedIDs(); !reflect.DeepEqual(got, []int{2}) {
+			t.Errorf("checked hook IDs after destroy = %v, want [2]", got)
+		}
+	})
+	if got := len(mock.requestsMatching("GET /repos/owner/repo/hooks")); got != 3 {
+		t.Errorf("listed hooks %d time(s), want 3 as the destroy invalidates the cache", got)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// MigrateOptions holds the options of the migrate process
+type MigrateOptions struct {
+	// OldURL is the config URL of hooks to migrate
+	OldURL string
+	// NewURL is the config URL hooks are migrated to
+	NewURL string
+	DryRun bool
+	Yes    bool
+}
+
+// parseMigrateFlag splits a migrate flag of the form old=new into its URLs
+// @arg migrateFlag string
+// @return string - Old URL
+// @return string - New URL
+// @return error
+func parseMigrateFlag(migrateFlag string) (string, string, error) {
+	parts := strings.SplitN(migrateFlag, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected old=new, got %q", migrateFlag)
+	}
+	return parts[0], parts[1], nil
+}
+
+// updateWebHookURL changes the config URL of a webhook, keeping the rest of its config
+// @arg ctx context.Context - Cancels requests when done
+// @arg hook WebHook
+// @arg newURL string
+// @return error
+func updateWebHookURL(ctx context.Context, hook WebHook, newURL string) error {
+	config := map[string]string{
+		"url":          newURL,
+		"content_type": hook.Config.ContentType,
+	}
+	return changeWebHooksOf(ctx, hook.Owner(), hook.URL+"/config", "PATCH", config)
+}
+
+// Executes the migration of webhooks from one config URL to another
+// @arg ctx context.Context - Cancels requests when done
+// @arg options MigrateOptions
+// @arg filter HookFilter
+// @return error
+func executeMigrate(ctx context.Context, options MigrateOptions, filter HookFilter) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("            M I G R A T E")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	fmt.Printf("%s %s %s %s\n\n", au.Bold(au.Gray("Webhooks with config url")), au.Bold(au.Brown(options.OldURL)), au.Bold(au.Gray("will be migrated to")), au.Bold(au.Brown(options.NewURL)))
+
+	// URLs delivering to the same place as the old URL are migrated too
+	oldURL := webhookit.NormalizeConfigURL(options.OldURL)
+	var hooksToMigrate []WebHook
+	var totalMigrateOutput string
+
+	failedRepos := 0
+
+	// For each repo...
+	for index, repo := range reposContainer.Repos {
+		if deadlineReached(ctx, index, len(reposContainer.Repos)) {
+			fmt.Println(au.Red("Migration skipped as the deadline was reached."))
+			return ctx.Err()
+		}
+		printProgress(index, len(reposContainer.Repos), repo.Name)
+
+		// Get web hooks
+		webHooks, err := getWebHooks(ctx, repo)
+		if err != nil {
+			clearProgress()
+			fmt.Print(repoErrorMessage(err))
+			if isRepoFailure(err) {
+				failedRepos++
+			}
+			continue
+		}
+		webHooks.Hooks = webhookit.FilterWebHooks(webHooks.Hooks, filter)
+
+		for _, hook := range webHooks.Hooks {
+			if webhookit.NormalizeConfigURL(hook.Config.URL) != oldURL {
+				continue
+			}
+			totalMigrateOutput += fmt.Sprintf("%s => %s\n", au.Bold(au.Magenta(repo.Name)), au.Bold(au.Gray(hook.URL)))
+			hooksToMigrate = append(hooksToMigrate, hook)
+		}
+	}
+
+	clearProgress()
+
+	if len(hooksToMigrate) == 0 {
+		fmt.Println(au.Green("Found no hooks to migrate."))
+		return scanError(ctx, failedRepos, len(reposContainer.Repos))
+	}
+	fmt.Printf("%s %d %s\n\n%s\n", au.Bold(au.Gray("Found")), au.Bold(au.Brown(len(hooksToMigrate))), au.Bold(au.Gray("hooks to migrate:")), totalMigrateOutput)
+
+	if options.DryRun {
+		fmt.Println(au.Bold(au.Green("DRY RUN - no webhooks were migrated")))
+		return scanError(ctx, failedRepos, len(reposContainer.Repos))
+	}
+
+	question := fmt.Sprintf("%s\n", au.Bold("Do you wish to migrate the selected web hooks?"))
+	if !confirmPassPhrase(question, "migration", options.Yes) {
+		fmt.Println(au.Green("\nMigration aborted."))
+		return scanError(ctx, failedRepos, len(reposContainer.Repos))
+	}
+
+	failed := 0
+	for _, hook := range hooksToMigrate {
+		if err := updateWebHookURL(ctx, hook, options.NewURL); err != nil {
+			fmt.Printf("- %s %s : %s\n", au.Red("Error migrating web hook"), hook.URL, au.Red(err))
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to migrate %d of %d web hooks", failed, len(hooksToMigrate))
+	}
+	fmt.Println(au.Green("\nMigration completed."))
+	return scanError(ctx, failedRepos, len(reposContainer.Repos))
+}
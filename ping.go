@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// pingSettleDelay is how long to wait after pinging the webhooks of a repo for
+// GitHub to deliver the pings before their status is fetched again
+var pingSettleDelay = 2 * time.Second
+
+// pingWebHook asks GitHub to send a ping event to a webhook
+// @arg ctx context.Context - Cancels requests when done
+// @arg hook WebHook
+// @return error
+func pingWebHook(ctx context.Context, hook WebHook) error {
+	return apiClient.Request(ctx, hook.PingURL, "POST", nil, nil)
+}
+
+// Executes a ping of every matched webhook so GitHub redelivers to it and
+// refreshes its last response. The webhooks pinged are then fetched again,
+// bypassing the cache, and their new status printed.
+// @arg ctx context.Context - Cancels requests when done
+// @arg filter HookFilter
+// @return error
+func executePing(ctx context.Context, filter HookFilter) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("               P I N G")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	fmt.Println(au.Bold(au.Gray("Pinging webhooks of GitHub repo(s)...\n")))
+
+	pinged, failed, failedRepos := 0, 0, 0
+
+	// For each repo...
+	for index, repo := range reposContainer.Repos {
+		if deadlineReached(ctx, index, len(reposContainer.Repos)) {
+			break
+		}
+		// Get web hooks
+		webHooks, err := getWebHooks(ctx, repo)
+		if err != nil {
+			fmt.Print(repoErrorMessage(err))
+			if isRepoFailure(err) {
+				failedRepos++
+			}
+			continue
+		}
+		webHooks.Hooks = webhookit.FilterWebHooks(webHooks.Hooks, filter)
+
+		fmt.Printf("%s\n\n", au.Bold(au.Magenta(repo.Name)))
+
+		pingedIDs := make(map[int]bool, len(webHooks.Hooks))
+		for _, hook := range webHooks.Hooks {
+			if err := pingWebHook(ctx, hook); err != nil {
+				fmt.Printf("%s => %s %s\n", au.Bold(au.Gray(hook.URL)), au.Red("Ping failed:"), au.Red(err))
+				failed++
+				continue
+			}
+			fmt.Printf("%s => %s\n", au.Bold(au.Gray(hook.URL)), au.Green("Pinged"))
+			pingedIDs[hook.ID] = true
+			pinged++
+		}
+
+		if len(pingedIDs) > 0 {
+			printPingedStatus(ctx, repo, pingedIDs)
+		}
+
+		// Newline to space out each repo
+		fmt.Println()
+	}
+
+	fmt.Printf("%s %d %s %d %s\n", au.Green("Ping complete."), au.Bold(au.Green(pinged)), au.Gray("pinged,"), au.Bold(au.Red(failed)), au.Gray("failed"))
+	if failed > 0 {
+		return fmt.Errorf("failed to ping %d of %d web hooks", failed, pinged+failed)
+	}
+	return scanError(ctx, failedRepos, len(reposContainer.Repos))
+}
+
+// printPingedStatus waits for pings to be delivered then fetches the webhooks of
+// a repo again, bypassing the cache, and prints the new status of those pinged
+// @arg ctx context.Context - Cancels requests when done
+// @arg repo Repo
+// @arg pingedIDs map[int]bool - IDs of the webhooks pinged
+func printPingedStatus(ctx context.Context, repo Repo, pingedIDs map[int]bool) {
+	select {
+	case <-time.After(pingSettleDelay):
+	case <-ctx.Done():
+		return
+	}
+
+	refreshCache := apiClient.RefreshCache
+	apiClient.RefreshCache = true
+	webHooks, err := getWebHooks(ctx, repo)
+	apiClient.RefreshCache = refreshCache
+	if err != nil {
+		fmt.Printf("%s %s\n", au.Red("Failed to retrieve status after ping:"), au.Red(err))
+		return
+	}
+
+	fmt.Printf("\n%s\n", au.Bold(au.Gray("Status after ping:")))
+	for _, hook := range webHooks.Hooks {
+		if pingedIDs[hook.ID] {
+			fmt.Println(statusToString(hook))
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package webhookit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// Cache stores the combined pages of lists of webhooks by the URL of their first page
+type Cache interface {
+	// Get returns the cached response of a request URL if it is still fresh
+	Get(requestURL string) ([]byte, bool)
+	// Put caches the response of a request URL
+	Put(requestURL string, response []byte)
+	// Delete removes the cached response of a request URL, if any
+	Delete(requestURL string)
+}
+
+// InvalidateWebHooks removes the cached list of the webhooks of a repo or
+// organization. Called after changing its webhooks so a later cached list does
+// not show them as they were.
+// @arg repo Repo
+func (c *Client) InvalidateWebHooks(repo Repo) {
+	if c.Cache != nil && repo.Name != "" {
+		c.Cache.Delete(listURL(c.HooksURL(repo)))
+	}
+}
+
+// List makes a GET API request of a list, following the Link header through every
+// page. The pages are combined into a single JSON array, which is stored in Cache
+// and read from it unless RefreshCache is set.
+// @arg ctx context.Context - Cancels requests when done
+// @arg requestURL string - API request url of the first page
+// @arg output interface{} - Decoded from the combined JSON array
+// @return error
+func (c *Client) List(ctx context.Context, requestURL string, output interface{}) error {
+	if c.Cache != nil && !c.RefreshCache {
+		if response, ok := c.Cache.Get(requestURL); ok {
+			c.logf(1, "GET %s (cached)", requestURL)
+			return json.Unmarshal(response, output)
+		}
+	}
+
+	items := []json.RawMessage{}
+	err := c.Paginate(ctx, requestURL, func(body io.Reader) error {
+		var page []json.RawMessage
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return err
+		}
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	response, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if c.Cache != nil {
+		c.Cache.Put(requestURL, response)
+	}
+	return json.Unmarshal(response, output)
+}
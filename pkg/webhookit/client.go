@@ -0,0 +1,570 @@
+package webhookit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the base URL of the GitHub API
+const DefaultBaseURL = "https://api.github.com"
+
+// DefaultAPIVersion is the version of the GitHub REST API requested by default
+const DefaultAPIVersion = "2022-11-28"
+
+// DefaultRetryDelay is the delay before the first retry of a failed request
+const DefaultRetryDelay = 500 * time.Millisecond
+
+// APIError is returned when an API request responds with an unsuccessful status code
+type APIError struct {
+	StatusCode int
+	// RequestID is the X-GitHub-Request-Id of the response, which GitHub support
+	// asks for when investigating a failed request. Empty if not returned.
+	RequestID string
+}
+
+// NewAPIError creates an APIError from an unsuccessful response
+// @arg response *http.Response
+// @return *APIError
+func NewAPIError(response *http.Response) *APIError {
+	return &APIError{
+		StatusCode: response.StatusCode,
+		RequestID:  response.Header.Get("X-GitHub-Request-Id"),
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s %d %s (X-GitHub-Request-Id: %s)", "HTTP Status Code", e.StatusCode, "returned", e.RequestID)
+	}
+	return fmt.Sprintf("%s %d %s", "HTTP Status Code", e.StatusCode, "returned")
+}
+
+// RepoNotFound is the Reason of repos skipped by Client.IgnoreNotFound
+const RepoNotFound = "not found"
+
+// RepoSkippedError is returned when the webhooks of a repo are not retrieved
+// because the repo is archived or disabled
+type RepoSkippedError struct {
+	Repo string
+	// Reason is the state of the repo, either archived, disabled or RepoNotFound
+	Reason string
+}
+
+func (e *RepoSkippedError) Error() string {
+	return fmt.Sprintf("Skipping %s repo: %s", e.Reason, e.Repo)
+}
+
+// NextPageURL returns the URL of the next page from a Link header, or an empty string on the last page
+// @arg linkHeader string
+// @return string
+func NextPageURL(linkHeader string) string {
+	for _, link := range strings.Split(linkHeader, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+// Client makes requests to the API of a Provider. It throttles requests, retries
+// rate limited and failed ones and caches lists of webhooks when configured to.
+// The zero value makes plain requests to the public GitHub API.
+type Client struct {
+	// HTTPClient makes the requests. Nil uses http.DefaultClient.
+	HTTPClient *http.Client
+	// Provider is the hosting service of the repos. Nil uses GitHubProvider.
+	Provider Provider
+	// Token is the API key sent with every request
+	Token string
+	// Authorization returns the Authorization header of each request, e.g. for a
+	// GitHub App installation token. Nil sends Token in the form of the Provider.
+	Authorization func(ctx context.Context) (string, error)
+	// APIVersion is sent to GitHub with the X-GitHub-Api-Version header. Empty sends none.
+	APIVersion string
+	// Headers are added to every request, replacing any set by the Client
+	Headers http.Header
+	// MaxRetries is the number of times a rate limited or failed request is retried
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, doubled with each attempt.
+	// Zero uses DefaultRetryDelay.
+	RetryDelay time.Duration
+	// RequestDelay is the minimum time between consecutive requests. Zero disables throttling.
+	RequestDelay time.Duration
+	// RepoTimeout bounds the time spent listing the webhooks of each repo, across
+	// every page of the list and every retry of each page. Zero disables it.
+	RepoTimeout time.Duration
+	// IgnoreNotFound returns a RepoSkippedError with Reason RepoNotFound when the
+	// webhooks of a repo respond with 404
+	IgnoreNotFound bool
+	// Cache stores lists of webhooks. Nil disables caching.
+	Cache Cache
+	// RefreshCache lists webhooks without reading Cache, e.g. so hooks are fresh
+	// before changing them. Lists are still written to Cache.
+	RefreshCache bool
+	// Verbosity is the level of request logging to Log. 1 logs requests and 2
+	// also logs the bodies of failed responses.
+	Verbosity int
+	// Log receives request logging. Nil disables logging.
+	Log io.Writer
+	// OnResponse is called after each request is made, e.g. to count requests.
+	// The response is nil if the request failed.
+	OnResponse func(response *http.Response)
+	// OnRateLimit is called before sleeping until a rate limit resets. Nil logs it.
+	OnRateLimit func(reset time.Time)
+
+	mutex       sync.Mutex
+	lastRequest time.Time
+}
+
+// NewClient creates a Client for the public GitHub API
+// @arg token string - API key
+// @return *Client
+func NewClient(token string) *Client {
+	return &Client{
+		HTTPClient: http.DefaultClient,
+		Provider:   GitHubProvider{},
+		Token:      token,
+		APIVersion: DefaultAPIVersion,
+	}
+}
+
+// provider returns the Provider of the Client, GitHub by default
+// @return Provider
+func (c *Client) provider() Provider {
+	if c.Provider == nil {
+		return GitHubProvider{}
+	}
+	return c.Provider
+}
+
+// APIURL returns the base URL of the API of the Provider
+// @return string
+func (c *Client) APIURL() string {
+	return c.provider().APIURL()
+}
+
+// HooksURL returns the API URL of the webhooks of a repo or organization
+// @arg repo Repo
+// @return string
+func (c *Client) HooksURL(repo Repo) string {
+	return c.provider().HooksURL(repo)
+}
+
+// logf writes a message to Log if Verbosity is at least level
+// @arg level int
+// @arg format string
+// @arg args ...interface{}
+func (c *Client) logf(level int, format string, args ...interface{}) {
+	if c.Log != nil && c.Verbosity >= level {
+		fmt.Fprintf(c.Log, format+"\n", args...)
+	}
+}
+
+// logResponse logs the status and remaining rate limit of a response. At verbosity 2
+// the body of failed responses is also logged and replaced so it can still be read.
+// @arg response *http.Response
+func (c *Client) logResponse(response *http.Response) {
+	c.logf(1, "  => %s (rate limit remaining: %s)", response.Status, response.Header.Get("X-RateLimit-Remaining"))
+	if c.Log == nil || c.Verbosity < 2 || response.StatusCode < 400 {
+		return
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		c.logf(2, "  => could not read body: %s", err)
+	}
+	c.logf(2, "  => %s", body)
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+}
+
+// throttle sleeps until at least RequestDelay has passed since the previous request
+func (c *Client) throttle() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.RequestDelay > 0 && !c.lastRequest.IsZero() {
+		if wait := c.RequestDelay - time.Since(c.lastRequest); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	c.lastRequest = time.Now()
+}
+
+// authorization returns the Authorization header value of a request
+// @arg ctx context.Context
+// @return string
+// @return error
+func (c *Client) authorization(ctx context.Context) (string, error) {
+	if c.Authorization != nil {
+		return c.Authorization(ctx)
+	}
+	return c.provider().Authorization(c.Token), nil
+}
+
+// Send executes a single request without retrying it. The API version and Headers
+// are added to the request, which must already be authorised.
+// @arg request *http.Request
+// @return *http.Response - Caller is responsible for closing the body
+// @return error
+func (c *Client) Send(request *http.Request) (*http.Response, error) {
+	// GitLab does not version its API by header
+	if _, ok := c.provider().(GitHubProvider); ok && c.APIVersion != "" {
+		request.Header.Set("X-GitHub-Api-Version", c.APIVersion)
+	}
+	// An Authorization header of Headers replaces the one of the token
+	for name, values := range c.Headers {
+		request.Header.Del(name)
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	c.throttle()
+	c.logf(1, "%s %s", request.Method, request.URL)
+	response, err := httpClient.Do(request)
+	if c.OnResponse != nil {
+		c.OnResponse(response)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.logResponse(response)
+	return response, nil
+}
+
+// isRateLimited returns whether a response was rejected due to GitHub rate limiting
+// @arg response *http.Response
+// @return bool
+func isRateLimited(response *http.Response) bool {
+	switch response.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return response.Header.Get("X-RateLimit-Remaining") == "0" || response.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// isPending returns whether a GET was accepted but its response is still being
+// computed, in which case the same request must be made again for the result
+// @arg method string
+// @arg response *http.Response
+// @return bool
+func isPending(method string, response *http.Response) bool {
+	return method == "GET" && response.StatusCode == http.StatusAccepted
+}
+
+// rateLimitReset returns the time at which a rate limited request can be retried
+// @arg response *http.Response
+// @return time.Time
+func rateLimitReset(response *http.Response) time.Time {
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	if reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		return time.Unix(reset, 0)
+	}
+	return time.Now().Add(time.Minute)
+}
+
+// Do executes an authorised API request, retrying up to MaxRetries times if the
+// request is rate limited, or with exponential backoff if a request other than
+// a POST fails with a network error or 5XX status code, or if a GET returns
+// 202 Accepted because the response is still being computed
+// @arg ctx context.Context - Cancels requests when done
+// @arg requestURL string - API request url
+// @arg method string - HTTP method to use
+// @arg body []byte - JSON request body or nil for none
+// @return *http.Response - Caller is responsible for closing the body
+// @return error
+func (c *Client) Do(ctx context.Context, requestURL, method string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		// Build request
+		request, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		authorization, err := c.authorization(ctx)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Add("Authorization", authorization)
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+
+		// Execute request
+		response, err := c.Send(request)
+		canRetry := attempt < c.MaxRetries
+		// Retrying a failed POST could create a resource twice
+		canRetryFailure := canRetry && method != "POST"
+		if err != nil {
+			c.logf(1, "%s %s failed: %s", method, requestURL, err)
+			if !canRetryFailure || ctx.Err() != nil {
+				return nil, err
+			}
+			if err := c.retryBackoff(ctx, attempt, method, requestURL); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		switch {
+		case isRateLimited(response) && canRetry:
+			response.Body.Close()
+
+			// Wait until the rate limit resets then try again
+			reset := rateLimitReset(response)
+			if c.OnRateLimit != nil {
+				c.OnRateLimit(reset)
+			} else {
+				c.logf(1, "Rate limited, sleeping until %s", reset.Format("15:04"))
+			}
+			if err := sleepContext(ctx, time.Until(reset)); err != nil {
+				return nil, err
+			}
+		case response.StatusCode >= 500 && canRetryFailure:
+			response.Body.Close()
+			if err := c.retryBackoff(ctx, attempt, method, requestURL); err != nil {
+				return nil, err
+			}
+		case isPending(method, response) && canRetry:
+			response.Body.Close()
+			c.logf(1, "%s %s is still being computed", method, requestURL)
+			if err := c.retryBackoff(ctx, attempt, method, requestURL); err != nil {
+				return nil, err
+			}
+		default:
+			return response, nil
+		}
+	}
+}
+
+// retryBackoff sleeps before retrying a failed request, doubling the delay with each attempt
+// @arg ctx context.Context - Cancels requests when done
+// @arg attempt int - Number of the failed attempt starting from 0
+// @arg method string
+// @arg requestURL string
+// @return error - The error of ctx if it is done before the delay ends
+func (c *Client) retryBackoff(ctx context.Context, attempt int, method, requestURL string) error {
+	delay := c.RetryDelay
+	if delay == 0 {
+		delay = DefaultRetryDelay
+	}
+	backoff := delay * time.Duration(1<<uint(attempt))
+	c.logf(1, "Retrying %s %s in %s (attempt %d of %d)", method, requestURL, backoff, attempt+1, c.MaxRetries)
+	return sleepContext(ctx, backoff)
+}
+
+// sleepContext sleeps for a duration, waking early if ctx is done
+// @arg ctx context.Context
+// @arg duration time.Duration
+// @return error - The error of ctx if it is done before the duration ends
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Request makes an API request, passing any received data into output
+// @arg ctx context.Context - Cancels requests when done
+// @arg requestURL string - API request url
+// @arg method string - HTTP method to use
+// @arg input interface{} - Object to send as the JSON request body or nil for none
+// @arg output interface{} - Object to output JSON response to or nil to discard it
+// @return error
+func (c *Client) Request(ctx context.Context, requestURL, method string, input, output interface{}) error {
+	var body []byte
+	if input != nil {
+		var err error
+		if body, err = json.Marshal(input); err != nil {
+			return err
+		}
+	}
+
+	response, err := c.Do(ctx, requestURL, method, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// A GET still pending after every retry has no result to decode
+	if response.StatusCode < 200 || response.StatusCode > 299 || isPending(method, response) {
+		return NewAPIError(response)
+	}
+	if output == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(output)
+}
+
+// Paginate makes a GET API request, following the Link header through every
+// page and passing the body of each page to handlePage
+// @arg ctx context.Context - Cancels requests when done
+// @arg requestURL string - API request url of the first page
+// @arg handlePage func(io.Reader) error - Decodes a page of results
+// @return error
+func (c *Client) Paginate(ctx context.Context, requestURL string, handlePage func(body io.Reader) error) error {
+	for requestURL != "" {
+		response, err := c.Do(ctx, requestURL, "GET", nil)
+		if err != nil {
+			return err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return NewAPIError(response)
+		}
+
+		err = handlePage(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return err
+		}
+		requestURL = NextPageURL(response.Header.Get("Link"))
+	}
+	return nil
+}
+
+// repoSkipReason retrieves the metadata of a GitHub repo to find whether it is
+// archived or disabled
+// @arg ctx context.Context - Cancels requests when done
+// @arg repo Repo
+// @return string - archived or disabled, or empty if neither or unknown
+func (c *Client) repoSkipReason(ctx context.Context, repo Repo) string {
+	if _, ok := c.provider().(GitHubProvider); !ok || repo.Org {
+		return ""
+	}
+	var metadata struct {
+		Archived bool `json:"archived"`
+		Disabled bool `json:"disabled"`
+	}
+	if err := c.Request(ctx, c.APIURL()+"/repos/"+repo.Name, "GET", nil, &metadata); err != nil {
+		c.logf(1, "Could not retrieve metadata of %s: %v", repo.Name, err)
+		return ""
+	}
+	switch {
+	case metadata.Disabled:
+		return "disabled"
+	case metadata.Archived:
+		return "archived"
+	}
+	return ""
+}
+
+// ListWebHooks retrieves every webhook of a repository or organization
+// @arg ctx context.Context - Cancels requests when done
+// @arg repo Repo
+// @return []WebHook - Each with Repo and Org set from repo
+// @return error - *RepoSkippedError if the repo is archived, disabled or not
+// found with IgnoreNotFound
+func (c *Client) ListWebHooks(ctx context.Context, repo Repo) ([]WebHook, error) {
+	if repo.Disabled {
+		return nil, &RepoSkippedError{Repo: repo.Name, Reason: "disabled"}
+	}
+
+	// Bound the time spent on the repo without affecting other repos
+	repoCtx := ctx
+	if c.RepoTimeout > 0 {
+		var cancel context.CancelFunc
+		repoCtx, cancel = context.WithTimeout(ctx, c.RepoTimeout)
+		defer cancel()
+	}
+
+	hooks, err := c.provider().DecodeWebHooks(repoCtx, c, c.HooksURL(repo))
+	if err != nil {
+		if ctx.Err() == nil && repoCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("Skipped after exceeding the repo timeout of %s: %s", c.RepoTimeout, repo.Name)
+		}
+		var apiError *APIError
+		if errors.As(err, &apiError) {
+			// Archived and disabled repos refuse some requests so explain why
+			switch apiError.StatusCode {
+			case http.StatusForbidden, http.StatusNotFound, http.StatusUnavailableForLegalReasons:
+				if reason := c.repoSkipReason(repoCtx, repo); reason != "" {
+					return nil, &RepoSkippedError{Repo: repo.Name, Reason: reason}
+				}
+			}
+			switch apiError.StatusCode {
+			case http.StatusNotFound:
+				if c.IgnoreNotFound {
+					return nil, &RepoSkippedError{Repo: repo.Name, Reason: RepoNotFound}
+				}
+				return nil, fmt.Errorf("Repository not found or no access: %s", repo.Name)
+			case http.StatusUnauthorized, http.StatusForbidden:
+				return nil, fmt.Errorf("Authentication failed — check token scopes: %s", repo.Name)
+			}
+		}
+		return nil, fmt.Errorf("API Request Error : %s encountered error : %s", repo.Name, err)
+	}
+
+	// Record owning repo on each hook
+	for i := range hooks {
+		hooks[i].Repo = repo.Name
+		hooks[i].Org = repo.Org
+	}
+	return hooks, nil
+}
+
+// DeleteWebHook destroys a webhook and invalidates the cached list of the
+// webhooks of its repo
+// @arg ctx context.Context - Cancels the request when done
+// @arg hook WebHook - Hook returned by ListWebHooks
+// @return error
+func (c *Client) DeleteWebHook(ctx context.Context, hook WebHook) error {
+	response, err := c.Do(ctx, hook.URL, "DELETE", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNoContent {
+		c.InvalidateWebHooks(hook.Owner())
+		return nil
+	}
+	return fmt.Errorf("Encountered error deleting %s: %v", hook.URL, NewAPIError(response))
+}
+
+// BrokenWebHooks retrieves the webhooks of a repository whose last response code
+// matches one of the types, as the CLI does when checking or destroying
+// @arg ctx context.Context - Cancels requests when done
+// @arg repo Repo
+// @arg types string - CSV list of types accepted by ParseTypes e.g. 4XX,5XX
+// @return []WebHook
+// @return error
+func (c *Client) BrokenWebHooks(ctx context.Context, repo Repo, types string) ([]WebHook, error) {
+	parsedTypes, err := ParseTypes(types)
+	if err != nil {
+		return nil, err
+	}
+	hooks, err := c.ListWebHooks(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	return MatchingTypes(hooks, parsedTypes)
+}
@@ -0,0 +1,391 @@
+package webhookit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is a fake API recording the requests made to it
+type testServer struct {
+	*httptest.Server
+	mutex sync.Mutex
+	// requests are the method and path of every request e.g. GET /repos/owner/repo/hooks?page=2
+	requests []string
+	// headers are the headers of every request
+	headers []http.Header
+}
+
+// newTestServer starts a fake API and a Client pointed at it that does not wait between retries
+// @arg t *testing.T
+// @arg handler http.HandlerFunc
+// @return *testServer
+// @return *Client
+func newTestServer(t *testing.T, handler http.HandlerFunc) (*testServer, *Client) {
+	t.Helper()
+	server := &testServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		server.mutex.Lock()
+		server.requests = append(server.requests, request.Method+" "+request.URL.RequestURI())
+		server.headers = append(server.headers, request.Header.Clone())
+		server.mutex.Unlock()
+		handler(writer, request)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient("test-token")
+	client.Provider = GitHubProvider{BaseURL: server.URL}
+	client.RetryDelay = time.Millisecond
+	return server, client
+}
+
+// requestCount returns the number of requests made to the server
+// @return int
+func (s *testServer) requestCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.requests)
+}
+
+// pagedHooks serves hooks two to a page with a Link header to the next page
+// @arg hooks []WebHook
+// @return http.HandlerFunc
+func pagedHooks(hooks []WebHook) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		page, err := strconv.Atoi(request.URL.Query().Get("page"))
+		if err != nil {
+			page = 1
+		}
+		start, end := (page-1)*2, page*2
+		if end > len(hooks) {
+			end = len(hooks)
+		}
+		if end < len(hooks) {
+			writer.Header().Set("Link", fmt.Sprintf(`<http://%s%s?per_page=100&page=%d>; rel="next"`, request.Host, request.URL.Path, page+1))
+		}
+		json.NewEncoder(writer).Encode(hooks[start:end])
+	}
+}
+
+func TestListWebHooksFollowsPagination(t *testing.T) {
+	tests := []struct {
+		hooks     int
+		wantPages int
+	}{
+		{0, 1},
+		{2, 1},
+		{3, 2},
+		{5, 3},
+	}
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.hooks), func(t *testing.T) {
+			var hooks []WebHook
+			for id := 1; id <= test.hooks; id++ {
+				hooks = append(hooks, WebHook{ID: id})
+			}
+			server, client := newTestServer(t, pagedHooks(append([]WebHook{}, hooks...)))
+
+			listed, err := client.ListWebHooks(context.Background(), Repo{Name: "owner/repo"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := fmt.Sprint(HookIDs(listed)), fmt.Sprint(HookIDs(hooks)); got != want {
+				t.Errorf("listed hooks %s, want %s", got, want)
+			}
+			for _, hook := range listed {
+				if hook.Repo != "owner/repo" {
+					t.Errorf("hook %d has repo %q, want owner/repo", hook.ID, hook.Repo)
+				}
+			}
+			if server.requestCount() != test.wantPages {
+				t.Errorf("made %d requests %v, want %d", server.requestCount(), server.requests, test.wantPages)
+			}
+			if server.requests[0] != "GET /repos/owner/repo/hooks?per_page=100" {
+				t.Errorf("first request %q, want GET /repos/owner/repo/hooks?per_page=100", server.requests[0])
+			}
+		})
+	}
+}
+
+func TestListWebHooksErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		repo           Repo
+		status         int
+		metadata       string
+		ignoreNotFound bool
+		wantErr        string
+		// wantSkipped is the Reason of the RepoSkippedError returned, if any
+		wantSkipped string
+	}{
+		{name: "not found", repo: Repo{Name: "owner/repo"}, status: 404, metadata: `{}`, wantErr: "Repository not found or no access: owner/repo"},
+		{name: "not found ignored", repo: Repo{Name: "owner/repo"}, status: 404, metadata: `{}`, ignoreNotFound: true, wantSkipped: RepoNotFound},
+		{name: "archived", repo: Repo{Name: "owner/repo"}, status: 403, metadata: `{"archived": true}`, wantSkipped: "archived"},
+		{name: "disabled", repo: Repo{Name: "owner/repo", Disabled: true}, wantSkipped: "disabled"},
+		{name: "unauthorized", repo: Repo{Name: "owner/repo"}, status: 401, wantErr: "Authentication failed"},
+		{name: "server error", repo: Repo{Name: "owner/repo"}, status: 500, wantErr: "HTTP Status Code 500 returned"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, client := newTestServer(t, func(writer http.ResponseWriter, request *http.Request) {
+				if request.URL.Path == "/repos/owner/repo" {
+					fmt.Fprint(writer, test.metadata)
+					return
+				}
+				writer.WriteHeader(test.status)
+			})
+			client.IgnoreNotFound = test.ignoreNotFound
+
+			_, err := client.ListWebHooks(context.Background(), test.repo)
+			var skipped *RepoSkippedError
+			switch {
+			case test.wantSkipped != "":
+				if !errors.As(err, &skipped) || skipped.Reason != test.wantSkipped {
+					t.Errorf("error = %v, want a RepoSkippedError with reason %q", err, test.wantSkipped)
+				}
+			case err == nil || !strings.Contains(err.Error(), test.wantErr):
+				t.Errorf("error = %v, want one containing %q", err, test.wantErr)
+			case errors.As(err, &skipped):
+				t.Errorf("error = %v, want one that does not skip the repo", err)
+			}
+		})
+	}
+}
+
+func TestListWebHooksRepoTimeout(t *testing.T) {
+	_, client := newTestServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(writer, `[]`)
+	})
+	client.RepoTimeout = 10 * time.Millisecond
+
+	_, err := client.ListWebHooks(context.Background(), Repo{Name: "owner/repo"})
+	if err == nil || !strings.Contains(err.Error(), "repo timeout") {
+		t.Errorf("error = %v, want one for the repo timeout", err)
+	}
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		statuses   []int
+		maxRetries int
+		wantStatus int
+		wantCalls  int
+	}{
+		{"5XX retried", "GET", []int{500, 502, 200}, 3, 200, 3},
+		{"5XX retries exhausted", "GET", []int{500, 500, 500}, 2, 500, 3},
+		{"no retries", "GET", []int{500, 200}, 0, 500, 1},
+		{"POST not retried on 5XX", "POST", []int{500, 201}, 3, 500, 1},
+		{"rate limit retried", "GET", []int{429, 200}, 1, 200, 2},
+		{"rate limited POST retried", "POST", []int{429, 201}, 1, 201, 2},
+		{"4XX not retried", "GET", []int{404, 200}, 3, 404, 1},
+		{"202 retried", "GET", []int{202, 202, 200}, 3, 200, 3},
+		{"202 retries exhausted", "GET", []int{202, 202}, 1, 202, 2},
+		{"POST 202 not retried", "POST", []int{202, 201}, 3, 202, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			server, client := newTestServer(t, func(writer http.ResponseWriter, request *http.Request) {
+				status := test.statuses[calls]
+				calls++
+				if status == http.StatusTooManyRequests {
+					writer.Header().Set("Retry-After", "0")
+				}
+				writer.WriteHeader(status)
+			})
+			client.MaxRetries = test.maxRetries
+
+			response, err := client.Do(context.Background(), server.URL+"/rate_limit", test.method, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != test.wantStatus {
+				t.Errorf("status %d, want %d", response.StatusCode, test.wantStatus)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("made %d requests, want %d", calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRequestWaitsForPendingResponse(t *testing.T) {
+	var calls int
+	_, client := newTestServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		calls++
+		if calls == 1 {
+			writer.WriteHeader(http.StatusAccepted)
+			return
+		}
+		writer.Write([]byte(`[{"id": 1}]`))
+	})
+	client.MaxRetries = 3
+
+	var hooks []WebHook
+	if err := client.Request(context.Background(), client.HooksURL(Repo{Name: "o/r"}), "GET", nil, &hooks); err != nil {
+		t.Fatalf("Request returned error: %v", err)
+	}
+	if len(hooks) != 1 || hooks[0].ID != 1 {
+		t.Errorf("decoded %v, want the hook of the response after the 202", hooks)
+	}
+}
+
+func TestSendAddsHeaders(t *testing.T) {
+	server, client := newTestServer(t, func(writer http.ResponseWriter, request *http.Request) {})
+	client.Headers = http.Header{"X-Gateway": {"gateway"}}
+
+	response, err := client.Do(context.Background(), server.URL+"/rate_limit", "GET", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	headers := server.headers[0]
+	for name, want := range map[string]string{
+		"Authorization":        "token test-token",
+		"X-Github-Api-Version": DefaultAPIVersion,
+		"X-Gateway":            "gateway",
+	} {
+		if got := headers.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+
+	// An Authorization header replaces the one of the token
+	client.Headers.Set("Authorization", "Bearer gateway")
+	response, err = client.Do(context.Background(), server.URL+"/rate_limit", "GET", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if got := server.headers[1].Values("Authorization"); len(got) != 1 || got[0] != "Bearer gateway" {
+		t.Errorf("Authorization headers %q, want only Bearer gateway", got)
+	}
+}
+
+func TestDeleteWebHook(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr bool
+	}{
+		{http.StatusNoContent, false},
+		{http.StatusNotFound, true},
+		{http.StatusForbidden, true},
+	}
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.status), func(t *testing.T) {
+			server, client := newTestServer(t, func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(test.status)
+			})
+
+			// The cached list of the repo is only invalidated once the hook is deleted
+			cache := memoryCache{listURL(client.HooksURL(Repo{Name: "owner/repo"})): []byte(`[]`)}
+			client.Cache = cache
+
+			err := client.DeleteWebHook(context.Background(), WebHook{ID: 1, URL: server.URL + "/repos/owner/repo/hooks/1", Repo: "owner/repo"})
+			if (err != nil) != test.wantErr {
+				t.Errorf("error = %v, want error %t", err, test.wantErr)
+			}
+			if cached := len(cache) == 1; cached != test.wantErr {
+				t.Errorf("list still cached = %t, want %t", cached, test.wantErr)
+			}
+			if server.requests[0] != "DELETE /repos/owner/repo/hooks/1" {
+				t.Errorf("request %q, want DELETE /repos/owner/repo/hooks/1", server.requests[0])
+			}
+		})
+	}
+}
+
+// memoryCache is a Cache held in memory
+type memoryCache map[string][]byte
+
+func (c memoryCache) Get(requestURL string) ([]byte, bool) {
+	response, ok := c[requestURL]
+	return response, ok
+}
+
+func (c memoryCache) Put(requestURL string, response []byte) {
+	c[requestURL] = response
+}
+
+func (c memoryCache) Delete(requestURL string) {
+	delete(c, requestURL)
+}
+
+func TestListWebHooksCache(t *testing.T) {
+	hooks := []WebHook{{ID: 1}, {ID: 2}, {ID: 3}}
+	server, client := newTestServer(t, pagedHooks(hooks))
+	client.Cache = memoryCache{}
+	repo := Repo{Name: "owner/repo"}
+
+	// Every page is cached together so the second list makes no requests
+	for i := 0; i < 2; i++ {
+		listed, err := client.ListWebHooks(context.Background(), repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(HookIDs(listed)); got != "[1 2 3]" {
+			t.Errorf("list %d returned hooks %s, want [1 2 3]", i, got)
+		}
+	}
+	if server.requestCount() != 2 {
+		t.Errorf("made %d requests, want 2", server.requestCount())
+	}
+
+	client.RefreshCache = true
+	if _, err := client.ListWebHooks(context.Background(), repo); err != nil {
+		t.Fatal(err)
+	}
+	if server.requestCount() != 4 {
+		t.Errorf("made %d requests after refreshing, want 4", server.requestCount())
+	}
+}
+
+func TestBrokenWebHooks(t *testing.T) {
+	hooks := make([]WebHook, 4)
+	for i, code := range []int{200, 404, 500, 0} {
+		hooks[i].ID = i + 1
+		hooks[i].LastResponse.Code = code
+	}
+	_, client := newTestServer(t, pagedHooks(hooks))
+
+	broken, err := client.BrokenWebHooks(context.Background(), Repo{Name: "owner/repo"}, "4XX,5XX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(HookIDs(broken)); got != "[2 3]" {
+		t.Errorf("broken hooks %s, want [2 3]", got)
+	}
+
+	if _, err := client.BrokenWebHooks(context.Background(), Repo{Name: "owner/repo"}, "6XX"); err == nil {
+		t.Error("invalid types returned no error")
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{`<https://api.github.com/repos/o/r/hooks?page=2>; rel="next", <https://api.github.com/repos/o/r/hooks?page=5>; rel="last"`, "https://api.github.com/repos/o/r/hooks?page=2"},
+		{`<https://api.github.com/repos/o/r/hooks?page=1>; rel="prev", <https://api.github.com/repos/o/r/hooks?page=1>; rel="first"`, ""},
+	}
+	for _, test := range tests {
+		if got := NextPageURL(test.header); got != test.want {
+			t.Errorf("NextPageURL(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package webhookit
+
+import (
+	"strings"
+	"time"
+)
+
+// HookFilter limits which webhooks are considered by check and destroy
+type HookFilter struct {
+	// Events matches hooks subscribed to at least one of the events. Empty matches all hooks.
+	Events []string
+	// Active matches hooks with the given active status. Nil matches all hooks.
+	Active *bool
+	// OlderThan matches hooks last updated longer ago than the duration. Zero matches all hooks.
+	OlderThan time.Duration
+	// ContentType matches hooks with the content type. Empty matches all hooks.
+	ContentType string
+	// CreatedAfter matches hooks created after the time. Zero matches all hooks.
+	CreatedAfter time.Time
+	// InsecureOnly matches only hooks known to have no secret configured
+	InsecureOnly bool
+}
+
+// Matches returns whether a webhook passes the filter
+// @arg hook WebHook
+// @return bool
+func (f HookFilter) Matches(hook WebHook) bool {
+	if len(f.Events) > 0 && !subscribedToAny(hook, f.Events) {
+		return false
+	}
+	if f.Active != nil && hook.Active != *f.Active {
+		return false
+	}
+	if f.OlderThan > 0 && time.Since(hook.UpdatedAt) <= f.OlderThan {
+		return false
+	}
+	if f.ContentType != "" && NormalizeContentType(hook.Config.ContentType) != NormalizeContentType(f.ContentType) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && !hook.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	if f.InsecureOnly && !hook.IsInsecure() {
+		return false
+	}
+	return true
+}
+
+// subscribedToAny returns whether a webhook is subscribed to any of the events
+// @arg hook WebHook
+// @arg events []string
+// @return bool
+func subscribedToAny(hook WebHook, events []string) bool {
+	for _, hookEvent := range hook.Events {
+		for _, event := range events {
+			if hookEvent == event {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterWebHooks returns the webhooks passing a filter
+// @arg hooks []WebHook
+// @arg filter HookFilter
+// @return []WebHook
+func FilterWebHooks(hooks []WebHook, filter HookFilter) []WebHook {
+	var filtered []WebHook
+	for _, hook := range hooks {
+		if filter.Matches(hook) {
+			filtered = append(filtered, hook)
+		}
+	}
+	return filtered
+}
+
+// NormalizeContentType converts a content type to the short form used by GitHub,
+// so application/json and json are treated as the same
+// @arg contentType string
+// @return string
+func NormalizeContentType(contentType string) string {
+	switch contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType {
+	case "application/json":
+		return "json"
+	case "application/x-www-form-urlencoded":
+		return "form"
+	}
+	return contentType
+}
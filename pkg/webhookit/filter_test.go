@@ -0,0 +1,63 @@
+package webhookit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFilterWebHooks(t *testing.T) {
+	active, inactive := true, false
+	old := TestHook(1, "https://a.example.com", 200)
+	old.Events = []string{"push"}
+	old.UpdatedAt = time.Now().Add(-48 * time.Hour)
+	old.CreatedAt = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	old.Config.ContentType = "json"
+
+	recent := TestHook(2, "https://b.example.com", 200)
+	recent.Events = []string{"pull_request", "issues"}
+	recent.UpdatedAt = time.Now()
+	recent.CreatedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent.Config.ContentType = "form"
+	recent.Active = false
+	recent.Config.Secret = ""
+
+	hooks := []WebHook{old, recent}
+	tests := []struct {
+		name   string
+		filter HookFilter
+		want   []int
+	}{
+		{"none", HookFilter{}, []int{1, 2}},
+		{"events", HookFilter{Events: []string{"issues", "release"}}, []int{2}},
+		{"active", HookFilter{Active: &active}, []int{1}},
+		{"inactive", HookFilter{Active: &inactive}, []int{2}},
+		{"older than", HookFilter{OlderThan: 24 * time.Hour}, []int{1}},
+		{"content type", HookFilter{ContentType: "application/json"}, []int{1}},
+		{"created after", HookFilter{CreatedAfter: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}, []int{2}},
+		{"insecure only", HookFilter{InsecureOnly: true}, []int{2}},
+		{"every field", HookFilter{Events: []string{"push"}, Active: &inactive}, []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, want := fmt.Sprint(HookIDs(FilterWebHooks(hooks, test.filter))), fmt.Sprint(test.want); got != want {
+				t.Errorf("FilterWebHooks = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNormalizeContentType(t *testing.T) {
+	tests := map[string]string{
+		"application/json":                  "json",
+		" Application/JSON ":                "json",
+		"application/x-www-form-urlencoded": "form",
+		"form":                              "form",
+		"":                                  "",
+	}
+	for contentType, want := range tests {
+		if got := NormalizeContentType(contentType); got != want {
+			t.Errorf("NormalizeContentType(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
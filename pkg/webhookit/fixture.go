@@ -0,0 +1,41 @@
+package webhookit
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// TestHook returns an active webhook with a secret whose last delivery responded
+// with code, for the tests of this package and of tools built on it
+// @arg id int
+// @arg configURL string
+// @arg code int - 0 for never triggered
+// @return WebHook
+func TestHook(id int, configURL string, code int) WebHook {
+	hook := WebHook{
+		ID:     id,
+		URL:    fmt.Sprintf("https://api.github.com/repos/o/r/hooks/%d", id),
+		Name:   "web",
+		Active: true,
+		Events: []string{"push"},
+	}
+	hook.Config.URL = configURL
+	hook.Config.ContentType = "json"
+	hook.Config.Secret = "********"
+	hook.LastResponse.Code = code
+	if code != 0 {
+		hook.LastResponse.Message = http.StatusText(code)
+	}
+	return hook
+}
+
+// HookIDs returns the IDs of webhooks in order
+// @arg hooks []WebHook
+// @return []int
+func HookIDs(hooks []WebHook) []int {
+	ids := []int{}
+	for _, hook := range hooks {
+		ids = append(ids, hook.ID)
+	}
+	return ids
+}
@@ -0,0 +1,200 @@
+package webhookit
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseTypes validates a CSV list of HTTP status codes or ranges of them e.g.
+// 404, 50X, 4XX or 400-404, from 1XX to 5XX. Ranges of codes are expanded to
+// each code in the range. "none" disables status code matching.
+// @arg types string - CSV string of types
+// @return []string - Contains each type as a string
+// @return error
+func ParseTypes(types string) ([]string, error) {
+	if strings.ToLower(types) == "none" {
+		return []string{"N/A"}, nil
+	}
+
+	var failedTypes []string
+	var parsed []string
+	r := regexp.MustCompile("^[1-5](\\d\\d|\\d[xX]|[xX][xX])$")
+	rangeRegex := regexp.MustCompile("^([1-5]\\d\\d)-([1-5]\\d\\d)$")
+
+	for _, aType := range strings.Split(types, ",") {
+		aType = strings.TrimSpace(aType)
+		if bounds := rangeRegex.FindStringSubmatch(aType); bounds != nil {
+			start, _ := strconv.Atoi(bounds[1])
+			end, _ := strconv.Atoi(bounds[2])
+			if start > end {
+				failedTypes = append(failedTypes, aType+" (start of range is after its end)")
+				continue
+			}
+			for code := start; code <= end; code++ {
+				parsed = append(parsed, strconv.Itoa(code))
+			}
+			continue
+		}
+		if !r.MatchString(aType) {
+			failedTypes = append(failedTypes, aType)
+		}
+		parsed = append(parsed, strings.ToUpper(aType))
+	}
+
+	if len(failedTypes) > 0 {
+		return parsed, fmt.Errorf("Invalid types found, expected status codes from 1XX to 5XX e.g. 404, 5XX or 400-404: %s", strings.Join(failedTypes, ", "))
+	}
+	return parsed, nil
+}
+
+// TypesRegex takes a string array and replaces any instances of 'X' in each
+// string with '\\d' then joins each string using '|'.
+// @arg types []string - Types returned by ParseTypes
+// @return string - Regex string
+func TypesRegex(types []string) string {
+	var newTypes []string
+
+	for _, aType := range types {
+		newTypes = append(newTypes, strings.Replace(aType, "X", "\\d", -1))
+	}
+	return strings.Join(newTypes, "|")
+}
+
+// CompileTypes compiles the regex of TypesRegex, which matches a last response
+// code formatted with strconv.Itoa
+// @arg types []string - Types returned by ParseTypes
+// @return *regexp.Regexp
+// @return error
+func CompileTypes(types []string) (*regexp.Regexp, error) {
+	return regexp.Compile(TypesRegex(types))
+}
+
+// MatchingTypes returns the webhooks whose last response code matches one of
+// the types. Hooks that were never triggered or report no status never match.
+// @arg hooks []WebHook
+// @arg types []string - Types returned by ParseTypes
+// @return []WebHook
+// @return error
+func MatchingTypes(hooks []WebHook, types []string) ([]WebHook, error) {
+	typesRegex, err := CompileTypes(types)
+	if err != nil {
+		return nil, err
+	}
+	var matching []WebHook
+	for _, hook := range hooks {
+		if hook.NoStatus || hook.LastResponse.Code == 0 {
+			continue
+		}
+		if typesRegex.MatchString(strconv.Itoa(hook.LastResponse.Code)) {
+			matching = append(matching, hook)
+		}
+	}
+	return matching, nil
+}
+
+// DestroyCriteria selects the webhooks to destroy. A hook is selected if it
+// matches any of the criteria.
+type DestroyCriteria struct {
+	// Types matches hooks by last response code, as compiled by CompileTypes. Nil matches no hooks.
+	Types *regexp.Regexp
+	// ExcludeTypes inverts Types so hooks whose last response code does not match
+	// are selected. Hooks that were never triggered or report no status never match.
+	ExcludeTypes bool
+	// URLMatch matches hooks by config URL. Nil matches no hooks.
+	URLMatch *regexp.Regexp
+	// URLList matches hooks whose normalized config URL is in the set. Nil matches no hooks.
+	URLList map[string]bool
+	// Untriggered matches hooks that have never been triggered
+	Untriggered bool
+	// NeverSucceeded matches hooks whose last delivery failed
+	NeverSucceeded bool
+	// ActiveOnly never selects inactive hooks, e.g. when deactivating them
+	ActiveOnly bool
+}
+
+// Matches returns whether a webhook is selected by the criteria
+// @arg hook WebHook
+// @return bool
+func (c DestroyCriteria) Matches(hook WebHook) bool {
+	if c.ActiveOnly && !hook.Active {
+		return false
+	}
+
+	// Hooks with no reported status have no code so are never matched by type
+	code := ""
+	if !hook.NoStatus {
+		code = strconv.Itoa(hook.LastResponse.Code)
+	}
+	matchesType := false
+	if c.Types != nil {
+		matchesType = c.Types.MatchString(code)
+		if c.ExcludeTypes {
+			// Untriggered hooks are only matched by Untriggered
+			matchesType = code != "" && code != "0" && !c.Types.MatchString(code)
+		}
+	}
+	matchesURL := (c.URLMatch != nil && c.URLMatch.MatchString(hook.Config.URL)) || c.URLList[NormalizeConfigURL(hook.Config.URL)]
+	matchesState := (c.Untriggered && code == "0") || (c.NeverSucceeded && hook.IsBroken())
+	return matchesType || matchesURL || matchesState
+}
+
+// NormalizeConfigURL converts a config URL to a canonical form so URLs delivering
+// to the same place compare equal. The scheme and host are lowercased, trailing
+// slashes are removed from the path and query parameters are sorted.
+// @arg configURL string
+// @return string - The normalized URL, or configURL unchanged if it cannot be parsed
+func NormalizeConfigURL(configURL string) string {
+	parsedURL, err := url.Parse(configURL)
+	if err != nil || parsedURL.Host == "" {
+		return configURL
+	}
+	parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+	parsedURL.Host = strings.ToLower(parsedURL.Host)
+	parsedURL.Path = strings.TrimRight(parsedURL.Path, "/")
+	parsedURL.RawPath = ""
+	if parsedURL.RawQuery != "" {
+		// Encode sorts by key
+		parsedURL.RawQuery = parsedURL.Query().Encode()
+	}
+	return parsedURL.String()
+}
+
+// DuplicateGroups groups webhooks delivering to the same normalized config URL.
+// Only groups of more than one webhook are returned, ordered by config URL with
+// the webhooks of each group ordered by ID. Hooks without a config URL are skipped.
+// @arg hooks []WebHook
+// @return [][]WebHook
+func DuplicateGroups(hooks []WebHook) [][]WebHook {
+	buckets := make(map[string][]WebHook)
+	for _, hook := range hooks {
+		// Hooks without a config URL can't be compared
+		if hook.Config.URL == "" {
+			continue
+		}
+		configURL := NormalizeConfigURL(hook.Config.URL)
+		buckets[configURL] = append(buckets[configURL], hook)
+	}
+
+	configURLs := make([]string, 0, len(buckets))
+	for configURL := range buckets {
+		configURLs = append(configURLs, configURL)
+	}
+	sort.Strings(configURLs)
+
+	var groups [][]WebHook
+	for _, configURL := range configURLs {
+		group := buckets[configURL]
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].ID < group[j].ID
+		})
+		groups = append(groups, group)
+	}
+	return groups
+}
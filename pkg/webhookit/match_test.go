@@ -0,0 +1,190 @@
+package webhookit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseTypes(t *testing.T) {
+	tests := []struct {
+		types   string
+		want    []string
+		wantErr bool
+		// errContains are the bad entries the error must list
+		errContains []string
+	}{
+		{types: "404", want: []string{"404"}},
+		{types: "2xx", want: []string{"2XX"}},
+		{types: "4xx, 50X", want: []string{"4XX", "50X"}},
+		{types: "none", want: []string{"N/A"}},
+		{types: "abc", wantErr: true},
+		{types: "6XX", wantErr: true, errContains: []string{"6XX"}},
+		{types: "000", wantErr: true, errContains: []string{"000"}},
+		{types: "404,6XX,000", wantErr: true, errContains: []string{"6XX", "000"}},
+		{types: "400-404", want: []string{"400", "401", "402", "403", "404"}},
+		{types: "404-400", wantErr: true, errContains: []string{"404-400"}},
+		{types: "400-404,5XX", want: []string{"400", "401", "402", "403", "404", "5XX"}},
+	}
+	for _, test := range tests {
+		t.Run(test.types, func(t *testing.T) {
+			got, err := ParseTypes(test.types)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseTypes(%q) error = %v, want error %t", test.types, err, test.wantErr)
+			}
+			for _, entry := range test.errContains {
+				if !strings.Contains(err.Error(), entry) {
+					t.Errorf("ParseTypes(%q) error = %v, want it to list %s", test.types, err, entry)
+				}
+			}
+			if !test.wantErr && strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("ParseTypes(%q) = %v, want %v", test.types, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatchingTypes(t *testing.T) {
+	hooks := []WebHook{
+		TestHook(1, "https://a.example.com", 200),
+		TestHook(2, "https://b.example.com", 404),
+		TestHook(3, "https://c.example.com", 500),
+		TestHook(4, "https://d.example.com", 0),
+		TestHook(5, "https://e.example.com", 301),
+	}
+	noStatus := TestHook(6, "https://f.example.com", 0)
+	noStatus.NoStatus = true
+	hooks = append(hooks, noStatus)
+
+	tests := []struct {
+		types string
+		want  []int
+	}{
+		{"4XX,5XX", []int{2, 3}},
+		{"404", []int{2}},
+		{"3XX", []int{5}},
+		{"400-404,5XX", []int{2, 3}},
+		{"405-499", []int{}},
+		{"none", []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.types, func(t *testing.T) {
+			types, err := ParseTypes(test.types)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matching, err := MatchingTypes(hooks, types)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := fmt.Sprint(HookIDs(matching)), fmt.Sprint(test.want); got != want {
+				t.Errorf("MatchingTypes(%s) = %s, want %s", test.types, got, want)
+			}
+		})
+	}
+}
+
+func TestDestroyCriteria(t *testing.T) {
+	inactive := TestHook(5, "https://e.example.com/hook", 404)
+	inactive.Active = false
+	noStatus := TestHook(6, "https://f.example.com", 0)
+	noStatus.NoStatus = true
+	hooks := []WebHook{
+		TestHook(1, "https://a.example.com", 200),
+		TestHook(2, "https://b.example.com/hook", 404),
+		TestHook(3, "https://c.example.com", 500),
+		TestHook(4, "https://d.example.com", 0),
+		inactive,
+		noStatus,
+	}
+	types, err := CompileTypes([]string{"4XX"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		criteria DestroyCriteria
+		want     []int
+	}{
+		{"types", DestroyCriteria{Types: types}, []int{2, 5}},
+		{"exclude types", DestroyCriteria{Types: types, ExcludeTypes: true}, []int{1, 3}},
+		{"untriggered", DestroyCriteria{Untriggered: true}, []int{4}},
+		{"never succeeded", DestroyCriteria{NeverSucceeded: true}, []int{2, 3, 5}},
+		{"url match", DestroyCriteria{URLMatch: regexp.MustCompile(`/hook$`)}, []int{2, 5}},
+		{"url list", DestroyCriteria{URLList: map[string]bool{"https://c.example.com": true}}, []int{3}},
+		{"any of", DestroyCriteria{Types: types, Untriggered: true}, []int{2, 4, 5}},
+		{"active only", DestroyCriteria{Types: types, ActiveOnly: true}, []int{2}},
+		{"none", DestroyCriteria{}, []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matched := []int{}
+			for _, hook := range hooks {
+				if test.criteria.Matches(hook) {
+					matched = append(matched, hook.ID)
+				}
+			}
+			if got, want := fmt.Sprint(matched), fmt.Sprint(test.want); got != want {
+				t.Errorf("matched %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNormalizeConfigURL(t *testing.T) {
+	tests := []struct {
+		configURL string
+		want      string
+	}{
+		{"https://Example.COM/hook/", "https://example.com/hook"},
+		{"HTTPS://example.com/hook?b=2&a=1", "https://example.com/hook?a=1&b=2"},
+		{"https://example.com/Hook", "https://example.com/Hook"},
+		{"not a url", "not a url"},
+	}
+	for _, test := range tests {
+		if got := NormalizeConfigURL(test.configURL); got != test.want {
+			t.Errorf("NormalizeConfigURL(%q) = %q, want %q", test.configURL, got, test.want)
+		}
+	}
+}
+
+func TestDuplicateGroups(t *testing.T) {
+	hooks := []WebHook{
+		TestHook(3, "https://b.example.com", 200),
+		TestHook(1, "https://a.example.com", 200),
+		TestHook(2, "https://b.example.com", 200),
+		TestHook(4, "https://c.example.com", 200),
+		TestHook(5, "", 200),
+		TestHook(6, "", 200),
+	}
+
+	groups := DuplicateGroups(hooks)
+	var got []string
+	for _, group := range groups {
+		got = append(got, fmt.Sprint(HookIDs(group)))
+	}
+	if want := []string{"[2 3]"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("DuplicateGroups = %v, want %v", got, want)
+	}
+}
+
+func TestDuplicateGroupsNormalizesURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+	}{
+		{"trailing slash", []string{"https://example.com/hook", "https://example.com/hook/"}},
+		{"host case", []string{"https://example.com/hook", "https://EXAMPLE.com/hook"}},
+		{"query order", []string{"https://example.com/hook?a=1&b=2", "https://example.com/hook?b=2&a=1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hooks := []WebHook{TestHook(1, test.urls[0], 200), TestHook(2, test.urls[1], 200)}
+			if groups := DuplicateGroups(hooks); len(groups) != 1 || len(groups[0]) != 2 {
+				t.Errorf("DuplicateGroups(%v) = %v, want one group of both hooks", test.urls, groups)
+			}
+		})
+	}
+}
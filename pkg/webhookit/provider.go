@@ -0,0 +1,199 @@
+package webhookit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListPageSize is the number of items requested per page of a list, the most
+// GitHub and GitLab allow
+const ListPageSize = 100
+
+// listURL returns the URL of the first page of a list, which is also its cache key
+// @arg requestURL string - e.g. returned by HooksURL
+// @return string
+func listURL(requestURL string) string {
+	return requestURL + "?per_page=" + strconv.Itoa(ListPageSize)
+}
+
+// DefaultGitLabBaseURL is the base URL of the GitLab API
+const DefaultGitLabBaseURL = "https://gitlab.com/api/v4"
+
+// Repo is the type representing a single repo
+type Repo struct {
+	Name string `json:"name"`
+	// Org marks Name as an organization whose org-level hooks are used
+	Org bool `json:"-"`
+	// Disabled marks repos known to be disabled, whose webhooks cannot be retrieved
+	Disabled bool `json:"-"`
+}
+
+// Provider is a git hosting service whose webhooks can be managed
+type Provider interface {
+	// HooksURL returns the API URL of the webhooks of a repo or organization
+	HooksURL(repo Repo) string
+	// DecodeWebHooks makes the requests to list every page of webhooks and converts them to WebHooks
+	DecodeWebHooks(ctx context.Context, client *Client, requestURL string) ([]WebHook, error)
+	// Authorization returns the Authorization header value for an API key
+	Authorization(token string) string
+	// APIURL returns the base URL of the API, without a trailing slash
+	APIURL() string
+}
+
+// GitHubProvider manages webhooks of GitHub repos and organizations
+type GitHubProvider struct {
+	// BaseURL overrides DefaultBaseURL e.g. for a mock server or GitHub Enterprise
+	BaseURL string
+}
+
+// APIURL returns the base URL of the GitHub API
+// @return string
+func (p GitHubProvider) APIURL() string {
+	if p.BaseURL != "" {
+		return strings.TrimRight(p.BaseURL, "/")
+	}
+	return DefaultBaseURL
+}
+
+// HooksURL returns the API URL of the webhooks of a repo or organization
+// @arg repo Repo
+// @return string
+func (p GitHubProvider) HooksURL(repo Repo) string {
+	if repo.Org {
+		return p.APIURL() + "/orgs/" + repo.Name + "/hooks"
+	}
+	return p.APIURL() + "/repos/" + repo.Name + "/hooks"
+}
+
+// DecodeWebHooks lists every page of webhooks at a hooks URL
+// @arg ctx context.Context - Cancels requests when done
+// @arg client *Client
+// @arg requestURL string - Returned by HooksURL
+// @return []WebHook
+// @return error
+func (GitHubProvider) DecodeWebHooks(ctx context.Context, client *Client, requestURL string) ([]WebHook, error) {
+	var hooks []WebHook
+	err := client.List(ctx, listURL(requestURL), &hooks)
+	return hooks, err
+}
+
+// Authorization returns the Authorization header value for a GitHub API key
+// @arg token string
+// @return string
+func (GitHubProvider) Authorization(token string) string {
+	return "token " + token
+}
+
+// GitLabProvider manages webhooks of GitLab projects and groups. Repo names are
+// project or group paths e.g. namespace/project.
+type GitLabProvider struct {
+	// BaseURL overrides DefaultGitLabBaseURL e.g. for a mock server or self-managed GitLab
+	BaseURL string
+}
+
+// APIURL returns the base URL of the GitLab API
+// @return string
+func (p GitLabProvider) APIURL() string {
+	if p.BaseURL != "" {
+		return strings.TrimRight(p.BaseURL, "/")
+	}
+	return DefaultGitLabBaseURL
+}
+
+// GitLabHook is the type representing a single webhook in the form
+// of what is returned from a GitLab API call
+type GitLabHook struct {
+	ID                       int       `json:"id"`
+	URL                      string    `json:"url"`
+	CreatedAt                time.Time `json:"created_at"`
+	PushEvents               bool      `json:"push_events"`
+	TagPushEvents            bool      `json:"tag_push_events"`
+	MergeRequestsEvents      bool      `json:"merge_requests_events"`
+	IssuesEvents             bool      `json:"issues_events"`
+	NoteEvents               bool      `json:"note_events"`
+	PipelineEvents           bool      `json:"pipeline_events"`
+	JobEvents                bool      `json:"job_events"`
+	WikiPageEvents           bool      `json:"wiki_page_events"`
+	ReleasesEvents           bool      `json:"releases_events"`
+	ConfidentialIssuesEvents bool      `json:"confidential_issues_events"`
+	AlertStatus              string    `json:"alert_status"`
+}
+
+// events returns the names of the events the hook is subscribed to
+// @return []string
+func (h GitLabHook) events() []string {
+	var events []string
+	subscriptions := []struct {
+		enabled bool
+		name    string
+	}{
+		{h.PushEvents, "push"},
+		{h.TagPushEvents, "tag_push"},
+		{h.MergeRequestsEvents, "merge_requests"},
+		{h.IssuesEvents, "issues"},
+		{h.ConfidentialIssuesEvents, "confidential_issues"},
+		{h.NoteEvents, "note"},
+		{h.PipelineEvents, "pipeline"},
+		{h.JobEvents, "job"},
+		{h.WikiPageEvents, "wiki_page"},
+		{h.ReleasesEvents, "releases"},
+	}
+	for _, subscription := range subscriptions {
+		if subscription.enabled {
+			events = append(events, subscription.name)
+		}
+	}
+	return events
+}
+
+// HooksURL returns the API URL of the webhooks of a project or group
+// @arg repo Repo
+// @return string
+func (p GitLabProvider) HooksURL(repo Repo) string {
+	if repo.Org {
+		return p.APIURL() + "/groups/" + url.PathEscape(repo.Name) + "/hooks"
+	}
+	return p.APIURL() + "/projects/" + url.PathEscape(repo.Name) + "/hooks"
+}
+
+// DecodeWebHooks lists every page of webhooks at a hooks URL and converts them
+// to WebHooks. GitLab reports neither the last response nor the secret of a hook.
+// @arg ctx context.Context - Cancels requests when done
+// @arg client *Client
+// @arg requestURL string - Returned by HooksURL
+// @return []WebHook
+// @return error
+func (GitLabProvider) DecodeWebHooks(ctx context.Context, client *Client, requestURL string) ([]WebHook, error) {
+	var gitlabHooks []GitLabHook
+	if err := client.List(ctx, listURL(requestURL), &gitlabHooks); err != nil {
+		return nil, err
+	}
+
+	hooks := make([]WebHook, len(gitlabHooks))
+	for i, gitlabHook := range gitlabHooks {
+		hooks[i] = WebHook{
+			ID:        gitlabHook.ID,
+			URL:       requestURL + "/" + strconv.Itoa(gitlabHook.ID),
+			Name:      fmt.Sprintf("gitlab-%d", gitlabHook.ID),
+			Events:    gitlabHook.events(),
+			Active:    gitlabHook.AlertStatus != "disabled",
+			CreatedAt: gitlabHook.CreatedAt,
+			NoStatus:  true,
+			// GitLab does not report whether a secret token is set
+			NoSecretStatus: true,
+		}
+		hooks[i].Config.URL = gitlabHook.URL
+	}
+	return hooks, nil
+}
+
+// Authorization returns the Authorization header value for a GitLab access token
+// @arg token string
+// @return string
+func (GitLabProvider) Authorization(token string) string {
+	return "Bearer " + token
+}
@@ -0,0 +1,58 @@
+package webhookit
+
+// Summary tallies the webhooks found by a check
+type Summary struct {
+	Repos          int `json:"repos"`
+	FailedRepos    int `json:"failed_repos"`
+	Hooks          int `json:"hooks"`
+	Healthy        int `json:"healthy"`
+	Broken         int `json:"broken"`
+	NeverTriggered int `json:"never_triggered"`
+	Duplicates     int `json:"duplicates"`
+	// DuplicateGroups is the number of config URLs shared by the duplicates
+	DuplicateGroups int `json:"duplicate_groups"`
+	NoSecret        int `json:"no_secret"`
+	EventsMismatch  int `json:"events_mismatch"`
+}
+
+// Add tallies a webhook. Hooks whose provider does not report a last response
+// are counted as neither healthy nor broken.
+// @arg hook WebHook
+// @arg duplicate bool - Whether the hook shares its config URL with another
+// @arg eventsMismatch bool - Whether the events of the hook differ from those expected
+func (s *Summary) Add(hook WebHook, duplicate, eventsMismatch bool) {
+	s.Hooks++
+	switch {
+	case hook.NoStatus:
+	case hook.IsBroken():
+		s.Broken++
+	case hook.LastResponse.Code == 0:
+		s.NeverTriggered++
+	default:
+		s.Healthy++
+	}
+	if duplicate {
+		s.Duplicates++
+	}
+	if hook.IsInsecure() {
+		s.NoSecret++
+	}
+	if eventsMismatch {
+		s.EventsMismatch++
+	}
+}
+
+// Merge adds the tallies of another summary, e.g. of each repo into a total
+// @arg other Summary
+func (s *Summary) Merge(other Summary) {
+	s.Repos += other.Repos
+	s.FailedRepos += other.FailedRepos
+	s.Hooks += other.Hooks
+	s.Healthy += other.Healthy
+	s.Broken += other.Broken
+	s.NeverTriggered += other.NeverTriggered
+	s.Duplicates += other.Duplicates
+	s.DuplicateGroups += other.DuplicateGroups
+	s.NoSecret += other.NoSecret
+	s.EventsMismatch += other.EventsMismatch
+}
@@ -0,0 +1,31 @@
+package webhookit
+
+import "testing"
+
+func TestSummary(t *testing.T) {
+	noStatus := TestHook(5, "https://e.example.com", 0)
+	noStatus.NoStatus = true
+	noStatus.NoSecretStatus = true
+	insecure := TestHook(4, "https://d.example.com", 200)
+	insecure.Config.Secret = ""
+
+	var repo Summary
+	repo.Add(TestHook(1, "https://a.example.com", 200), false, false)
+	repo.Add(TestHook(2, "https://b.example.com", 404), true, false)
+	repo.Add(TestHook(3, "https://c.example.com", 0), true, true)
+	repo.Add(insecure, false, false)
+	repo.Add(noStatus, false, false)
+
+	want := Summary{Hooks: 5, Healthy: 2, Broken: 1, NeverTriggered: 1, Duplicates: 2, NoSecret: 1, EventsMismatch: 1}
+	if repo != want {
+		t.Errorf("Summary = %+v, want %+v", repo, want)
+	}
+
+	total := Summary{Repos: 1, FailedRepos: 1}
+	total.Merge(repo)
+	total.Merge(repo)
+	want = Summary{Repos: 1, FailedRepos: 1, Hooks: 10, Healthy: 4, Broken: 2, NeverTriggered: 2, Duplicates: 4, NoSecret: 2, EventsMismatch: 2}
+	if total != want {
+		t.Errorf("merged Summary = %+v, want %+v", total, want)
+	}
+}
@@ -0,0 +1,78 @@
+// Package webhookit checks the webhooks of GitHub repositories and organizations
+// for failed deliveries and destroys them. It is the library behind the webhookit
+// CLI and can be imported to embed the same checks in other tools.
+package webhookit
+
+import "time"
+
+// WebHook is the type representing a single webhook in the form
+// of what is returned from a GitHub API call. Fields tagged diff:"-" are
+// bookkeeping rather than hook settings and are left out of hook diffs.
+type WebHook struct {
+	// Repo is the repository owning the hook. It is not returned by the
+	// GitHub API and is populated when listing hooks so backups can be restored.
+	Repo string `json:"repo" diff:"-"`
+	// Org marks Repo as an organization owning an org-level hook. Like Repo it
+	// is populated when listing hooks.
+	Org     bool     `json:"org,omitempty" diff:"-"`
+	ID      int      `json:"id"`
+	URL     string   `json:"url"`
+	TestURL string   `json:"test_url"`
+	PingURL string   `json:"ping_url"`
+	Name    string   `json:"name"`
+	Events  []string `json:"events"`
+	Active  bool     `json:"active"`
+	Config  struct {
+		URL         string `json:"url"`
+		ContentType string `json:"content_type"`
+		// Secret is redacted by GitHub to ******** when one is configured
+		Secret string `json:"secret,omitempty"`
+	} `json:"config"`
+	// NoStatus marks hooks whose provider does not report a last response
+	NoStatus bool `json:"-" diff:"-"`
+	// NoSecretStatus marks hooks whose provider does not report whether a secret is configured
+	NoSecretStatus bool      `json:"-" diff:"-"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastResponse   struct {
+		Code    int    `json:"code"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"last_response"`
+}
+
+// Owner returns the repo or organization owning the web hook, as set when listing hooks
+// @return Repo
+func (w WebHook) Owner() Repo {
+	return Repo{Name: w.Repo, Org: w.Org}
+}
+
+// HasSecret returns whether the web hook has a secret configured to sign its payloads
+// @return bool
+func (w WebHook) HasSecret() bool {
+	return w.Config.Secret != ""
+}
+
+// IsInsecure returns whether the web hook is known to have no secret configured,
+// meaning its payloads are unsigned
+// @return bool
+func (w WebHook) IsInsecure() bool {
+	return !w.NoSecretStatus && !w.HasSecret()
+}
+
+// IsBroken returns whether the last delivery of the web hook failed. Hooks that
+// have never been triggered are not considered broken.
+// @return bool
+func (w WebHook) IsBroken() bool {
+	if w.NoStatus {
+		return false
+	}
+	code := w.LastResponse.Code
+	return code != 0 && (code < 200 || code > 299)
+}
+
+// HasProblem returns whether the web hook is broken or has never been triggered
+// @return bool
+func (w WebHook) HasProblem() bool {
+	return w.IsBroken() || (!w.NoStatus && w.LastResponse.Code == 0)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// normalizeAPIURL validates a base URL of an API and removes any trailing slashes so
+// paths can be appended to it. The base may include a path prefix such as the
+// /api/v3 of GitHub Enterprise Server, which is kept ahead of every request path.
+// @arg rawURL string - e.g. https://github.example.com/api/v3/
+// @return string - e.g. https://github.example.com/api/v3
+// @return error
+func normalizeAPIURL(rawURL string) (string, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return "", fmt.Errorf("it must include a scheme and host")
+	}
+	if parsedURL.RawQuery != "" || parsedURL.Fragment != "" {
+		return "", fmt.Errorf("it must not include a query or fragment")
+	}
+	return strings.TrimRight(rawURL, "/"), nil
+}
+
+// newProvider returns the Provider of a -provider name
+// @arg name string - github or gitlab
+// @arg baseURL string - Base URL of the API, or empty for the public API
+// @return webhookit.Provider
+func newProvider(name, baseURL string) webhookit.Provider {
+	if name == "gitlab" {
+		return webhookit.GitLabProvider{BaseURL: baseURL}
+	}
+	return webhookit.GitHubProvider{BaseURL: baseURL}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RateLimit is the core API request budget of the API key
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// getRateLimit retrieves the core rate limit of the API key. Requests to the
+// rate limit endpoint do not count against the limit.
+// @arg ctx context.Context - Cancels requests when done
+// @return RateLimit
+// @return error
+func getRateLimit(ctx context.Context) (RateLimit, error) {
+	var response struct {
+		Resources struct {
+			Core struct {
+				Limit     int   `json:"limit"`
+				Remaining int   `json:"remaining"`
+				Reset     int64 `json:"reset"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if err := apiClient.Request(ctx, apiClient.APIURL()+"/rate_limit", "GET", nil, &response); err != nil {
+		return RateLimit{}, err
+	}
+	core := response.Resources.Core
+	return RateLimit{Limit: core.Limit, Remaining: core.Remaining, Reset: time.Unix(core.Reset, 0)}, nil
+}
+
+// ToString returns a formatted string of the remaining requests and reset time
+func (r RateLimit) ToString() string {
+	remaining := au.Bold(au.Green(r.Remaining))
+	if r.Remaining < r.Limit/10 {
+		remaining = au.Bold(au.Red(r.Remaining))
+	}
+	return fmt.Sprintf("%s %d %s %d %s %s", au.Gray("Remaining requests:"), remaining, au.Gray("of"), au.Bold(r.Limit), au.Gray("- resets at"), au.Bold(au.Brown(r.Reset.Format("15:04"))))
+}
+
+// Executes a report of the rate limit of the API key
+// @arg ctx context.Context - Cancels requests when done
+// @return error
+func executeRateLimit(ctx context.Context) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("         R A T E   L I M I T")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	rateLimit, err := getRateLimit(ctx)
+	if err != nil {
+		printError("Issue retrieving rate limit:", err)
+	}
+	fmt.Println(rateLimit.ToString())
+
+	if repoCount := len(reposContainer.Repos); repoCount > 0 {
+		if rateLimit.Remaining < repoCount {
+			fmt.Println(au.Red(fmt.Sprintf("\nNot enough requests remaining to scan %d repo(s).", repoCount)))
+		} else {
+			fmt.Println(au.Green(fmt.Sprintf("\nEnough requests remaining to scan %d repo(s).", repoCount)))
+		}
+	}
+	return nil
+}
+
+// checkRateLimitBudget exits before a destroy run starts if fewer requests remain
+// than there are repos to scan, since running out part way leaves it half done
+func checkRateLimitBudget(ctx context.Context) {
+	rateLimit, err := getRateLimit(ctx)
+	if err != nil {
+		// The budget is only a precaution so do not stop the run
+		logVerbose(1, "Could not check rate limit: %v", err)
+		return
+	}
+	if repoCount := len(reposContainer.Repos); rateLimit.Remaining < repoCount {
+		printError(fmt.Sprintf("Only %d API requests remain until %s, fewer than the %d repo(s) to scan. Wait for the limit to reset or scan fewer repos.", rateLimit.Remaining, rateLimit.Reset.Format("15:04"), repoCount))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Executes a print of the raw JSON responses listing the webhooks of each repo,
+// as returned by the API, for debugging
+// @arg ctx context.Context - Cancels requests when done
+// @return error
+func executeRaw(ctx context.Context) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("                R A W")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	failedRepos := 0
+
+	// For each repo...
+	for index, repo := range reposContainer.Repos {
+		if deadlineReached(ctx, index, len(reposContainer.Repos)) {
+			break
+		}
+		requestURL := apiClient.HooksURL(repo)
+		fmt.Printf("%s %s\n\n", au.Bold(au.Magenta(repo.Name)), au.Gray(requestURL))
+
+		var response ResponseJSON
+		if err := apiClient.Request(ctx, requestURL, "GET", nil, &response); err != nil {
+			fmt.Print(repoErrorMessage(err))
+			failedRepos++
+			continue
+		}
+
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, response, "", "  "); err != nil {
+			// Print the response as returned if it is not valid JSON
+			fmt.Printf("%s\n\n", response)
+			continue
+		}
+		fmt.Printf("%s\n\n", indented.String())
+	}
+
+	return scanError(ctx, failedRepos, len(reposContainer.Repos))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// HookRequest is the body sent to GitHub when creating a webhook
+type HookRequest struct {
+	Name   string   `json:"name"`
+	Active bool     `json:"active"`
+	Events []string `json:"events"`
+	Config struct {
+		URL         string `json:"url"`
+		ContentType string `json:"content_type"`
+	} `json:"config"`
+}
+
+// readBackup reads webhooks from a JSON backup file produced by backupWebHooks,
+// erroring if the schema version of the file is not backupSchemaVersion
+// @arg filepath string
+// @return Backup
+// @return error
+func readBackup(filepath string) (Backup, error) {
+	var backup Backup
+
+	jsonBytes, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return Backup{}, err
+	}
+	if err := json.Unmarshal(jsonBytes, &backup); err != nil {
+		return Backup{}, err
+	}
+
+	// Backups from before versioning are version 1
+	if backup.SchemaVersion == 0 {
+		backup.SchemaVersion = backup.LegacyVersion
+	}
+	if backup.SchemaVersion == 0 {
+		backup.SchemaVersion = 1
+	}
+
+	switch {
+	case backup.SchemaVersion < backupSchemaVersion:
+		return Backup{}, fmt.Errorf("backup schema version %d is older than the supported version %d. Take a new backup with -b to upgrade it", backup.SchemaVersion, backupSchemaVersion)
+	case backup.SchemaVersion > backupSchemaVersion:
+		return Backup{}, fmt.Errorf("backup schema version %d is newer than the supported version %d. Upgrade webhookit to read it", backup.SchemaVersion, backupSchemaVersion)
+	}
+	return backup, nil
+}
+
+// createWebHook creates a webhook on a repository or organization using the config and events of hook
+// @arg ctx context.Context - Cancels requests when done
+// @arg repo Repo
+// @arg hook WebHook
+// @return error
+func createWebHook(ctx context.Context, repo Repo, hook WebHook) error {
+	hookRequest := HookRequest{
+		Name:   "web",
+		Active: hook.Active,
+		Events: hook.Events,
+	}
+	hookRequest.Config.URL = hook.Config.URL
+	hookRequest.Config.ContentType = hook.Config.ContentType
+
+	return changeWebHooksOf(ctx, repo, apiClient.HooksURL(repo), "POST", hookRequest)
+}
+
+// Executes the restore of webhooks from a backup file. Org-level hooks are recreated
+// on their organization. Hooks whose normalized config URL already exists on their
+// repo or organization are skipped.
+// @arg ctx context.Context - Cancels requests when done
+// @arg filepath string
+// @return error
+func executeRestore(ctx context.Context, filepath string) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("            R E S T O R E")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	backup, err := readBackup(filepath)
+	if err != nil {
+		printError("Issue reading backup file:", err)
+	}
+
+	fmt.Println(au.Bold(au.Gray(fmt.Sprintf("Restoring %d webhook(s) from %s...\n", len(backup.Hooks), filepath))))
+
+	// Normalized config URLs of the hooks currently present on each repo
+	existingURLs := make(map[Repo]map[string]bool)
+	recreated, skipped, failed := 0, 0, 0
+
+	for index, hook := range backup.Hooks {
+		if ctx.Err() != nil {
+			fmt.Printf("%s\n", au.Red(fmt.Sprintf("Deadline reached after %d of %d hook(s). Remaining hooks were skipped.", index, len(backup.Hooks))))
+			break
+		}
+		if hook.Repo == "" {
+			fmt.Printf("%s %s\n", au.Red("Skipping hook with no owning repo:"), au.Red(hook.URL))
+			skipped++
+			continue
+		}
+
+		// Fetch the current hooks of the repo the first time it is seen
+		repo := Repo{Name: hook.Repo, Org: hook.Org}
+		configURL := webhookit.NormalizeConfigURL(hook.Config.URL)
+		if _, ok := existingURLs[repo]; !ok {
+			webHooks, err := getWebHooks(ctx, repo)
+			if err != nil {
+				fmt.Printf("%s %s\n", au.Red("Failed to retrieve web hooks:"), au.Red(err))
+				failed++
+				continue
+			}
+			existingURLs[repo] = make(map[string]bool, len(webHooks.Hooks))
+			for _, existing := range webHooks.Hooks {
+				existingURLs[repo][webhookit.NormalizeConfigURL(existing.Config.URL)] = true
+			}
+		}
+
+		if existingURLs[repo][configURL] {
+			fmt.Printf("%s => %s\n", au.Bold(au.Magenta(hook.Repo)), au.Gray(hook.Config.URL+" already exists, skipping"))
+			skipped++
+			continue
+		}
+
+		if err := createWebHook(ctx, repo, hook); err != nil {
+			fmt.Printf("%s => %s %s\n", au.Bold(au.Magenta(hook.Repo)), au.Red("Error recreating "+hook.Config.URL+":"), au.Red(err))
+			failed++
+			continue
+		}
+		existingURLs[repo][configURL] = true
+		fmt.Printf("%s => %s\n", au.Bold(au.Magenta(hook.Repo)), au.Green(hook.Config.URL+" recreated"))
+		recreated++
+	}
+
+	fmt.Printf("\n%s %d %s %d %s %d %s\n", au.Green("Restore complete."), au.Bold(au.Green(recreated)), au.Gray("recreated,"), au.Bold(au.Brown(skipped)), au.Gray("skipped,"), au.Bold(au.Red(failed)), au.Gray("failed"))
+	if failed > 0 {
+		return fmt.Errorf("failed to recreate %d of %d web hooks", failed, len(backup.Hooks))
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eimlav/webhookit/pkg/webhookit"
+)
+
+// tokenExpiryWarning is how long before the API key expires a warning is printed
+const tokenExpiryWarning = 7 * 24 * time.Hour
+
+// tokenExpiryLayouts are the formats GitHub uses for the expiration of tokens
+var tokenExpiryLayouts = []string{"2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700"}
+
+// tokenExpiryWarned ensures the expiry warning is printed once per run
+var tokenExpiryWarned sync.Once
+
+// tokenExpiration returns when the API key expires from the
+// GitHub-Authentication-Token-Expiration header. Only tokens with an expiry,
+// such as fine-grained tokens, report it.
+// @arg response *http.Response
+// @return time.Time
+// @return bool - Whether the expiration was reported
+func tokenExpiration(response *http.Response) (time.Time, bool) {
+	header := response.Header.Get("GitHub-Authentication-Token-Expiration")
+	if header == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range tokenExpiryLayouts {
+		if expiry, err := time.Parse(layout, header); err == nil {
+			return expiry, true
+		}
+	}
+	logVerbose(1, "Could not parse token expiration: %s", header)
+	return time.Time{}, false
+}
+
+// warnTokenExpiry prints a warning, once, if the API key used for a request
+// expires within tokenExpiryWarning so it can be rotated before a scan fails
+// @arg response *http.Response
+func warnTokenExpiry(response *http.Response) {
+	expiry, ok := tokenExpiration(response)
+	if !ok || time.Until(expiry) > tokenExpiryWarning {
+		return
+	}
+	tokenExpiryWarned.Do(func() {
+		remaining := time.Until(expiry)
+		if remaining <= 0 {
+			fmt.Fprintln(infoOutput, au.Red(fmt.Sprintf("Warning: the API key expired on %s. Rotate it.\n", expiry.Format("2006-01-02 15:04 MST"))))
+			return
+		}
+		fmt.Fprintln(infoOutput, au.Brown(fmt.Sprintf("Warning: the API key expires on %s, in %s. Rotate it before it stops working.\n", expiry.Format("2006-01-02 15:04 MST"), remaining.Round(time.Hour))))
+	})
+}
+
+// grantedScopes returns the OAuth scopes of the API key from the X-OAuth-Scopes
+// header. Fine-grained tokens and GitHub Apps do not report scopes.
+// @arg ctx context.Context - Cancels requests when done
+// @return []string
+// @return bool - Whether scopes were reported
+// @return error
+func grantedScopes(ctx context.Context) ([]string, bool, error) {
+	response, err := apiClient.Do(ctx, apiClient.APIURL()+"/rate_limit", "GET", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, false, webhookit.NewAPIError(response)
+	}
+	header, ok := response.Header["X-Oauth-Scopes"]
+	if !ok {
+		return nil, false, nil
+	}
+	return splitCSV(strings.Join(header, ",")), true, nil
+}
+
+// repoPermissions holds the permissions of the API key on a repo, which GitHub
+// reports when the repo is retrieved
+type repoPermissions struct {
+	Permissions *struct {
+		Admin bool `json:"admin"`
+	} `json:"permissions"`
+}
+
+// orgMembership holds the role of the user of the API key in an organization
+type orgMembership struct {
+	Role string `json:"role"`
+}
+
+// canAdminWebHooks probes whether the API key administers a repo or organization,
+// which changing its webhooks requires. Used for tokens that do not report scopes.
+// @arg ctx context.Context - Cancels requests when done
+// @arg repo Repo
+// @return bool
+// @return error
+func canAdminWebHooks(ctx context.Context, repo Repo) (bool, error) {
+	if repo.Org {
+		var membership orgMembership
+		if err := apiClient.Request(ctx, apiClient.APIURL()+"/user/memberships/orgs/"+repo.Name, "GET", nil, &membership); err != nil {
+			return false, err
+		}
+		return membership.Role == "admin", nil
+	}
+
+	var permissions repoPermissions
+	if err := apiClient.Request(ctx, apiClient.APIURL()+"/repos/"+repo.Name, "GET", nil, &permissions); err != nil {
+		return false, err
+	}
+	if permissions.Permissions == nil {
+		return false, errors.New("permissions of the API key were not reported")
+	}
+	return permissions.Permissions.Admin, nil
+}
+
+// destroyScopesError checks the API key can change the webhooks of repos before a
+// destroy or deactivate starts, as every change would otherwise fail with 403.
+// Tokens reporting scopes must have the scope to change webhooks. Fine-grained
+// tokens do not, so must administer every repo or organization instead.
+// @arg ctx context.Context - Cancels requests when done
+// @arg repos []Repo
+// @return error - Describes why the API key cannot change webhooks, or nil
+func destroyScopesError(ctx context.Context, repos []Repo) error {
+	scopes, reported, err := grantedScopes(ctx)
+	if err != nil {
+		// The scopes are only a precaution so do not stop the run
+		logVerbose(1, "Could not check token scopes: %v", err)
+		return nil
+	}
+
+	if reported {
+		required := []string{"repo", "admin:repo_hook"}
+		for _, repo := range repos {
+			if repo.Org {
+				required = []string{"admin:org_hook"}
+			}
+		}
+		if !containsAnyString(scopes, required) {
+			return fmt.Errorf("The API key cannot change webhooks. It needs the %s scope but has: %s", strings.Join(required, " or "), strings.Join(scopes, ", "))
+		}
+		return nil
+	}
+
+	// Installation tokens have the permissions granted to the app instead
+	if apiClient.Authorization != nil {
+		logVerbose(1, "GitHub App permissions not checked, assuming the app can change webhooks")
+		return nil
+	}
+
+	var denied []string
+	for _, repo := range repos {
+		admin, err := canAdminWebHooks(ctx, repo)
+		var apiErr *webhookit.APIError
+		if apiClient.IgnoreNotFound && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Could not verify the API key can change the webhooks of %s: %v", repo.Name, err)
+		}
+		if !admin {
+			denied = append(denied, repo.Name)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("The API key does not report its scopes and lacks admin access to change the webhooks of: %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// checkDestroyScopes exits before a destroy or deactivate starts if the API key
+// cannot change the webhooks of repos. See destroyScopesError.
+// @arg ctx context.Context - Cancels requests when done
+// @arg repos []Repo
+func checkDestroyScopes(ctx context.Context, repos []Repo) {
+	if err := destroyScopesError(ctx, repos); err != nil {
+		printError(err)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultServeInterval is the time between the scans of -serve
+const defaultServeInterval = 5 * time.Minute
+
+// ServeState holds the results of the latest scan of -serve
+type ServeState struct {
+	mutex sync.RWMutex
+	// Repos is the tally of each repo in the latest scan
+	Repos map[string]CheckSummary
+	// Summary is the tally of every repo in the latest scan
+	Summary CheckSummary
+	// LastScan is the time the latest scan completed. Zero until the first scan completes.
+	LastScan time.Time
+	// Duration is how long the latest scan took
+	Duration time.Duration
+}
+
+// update replaces the results with those of a scan
+// @arg repos map[string]CheckSummary
+// @arg duration time.Duration
+func (s *ServeState) update(repos map[string]CheckSummary, duration time.Duration) {
+	summary := CheckSummary{}
+	for _, repo := range repos {
+		summary.Merge(repo)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Repos = repos
+	s.Summary = summary
+	s.LastScan = time.Now()
+	s.Duration = duration
+}
+
+// prometheusLabelEscaper escapes label values of the Prometheus text format
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetrics writes the results in the Prometheus text format
+// @arg writer http.ResponseWriter
+func (s *ServeState) writeMetrics(writer http.ResponseWriter) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	repoNames := make([]string, 0, len(s.Repos))
+	for name := range s.Repos {
+		repoNames = append(repoNames, name)
+	}
+	sort.Strings(repoNames)
+
+	gauges := []struct {
+		name  string
+		help  string
+		value func(CheckSummary) int
+	}{
+		{"webhookit_hooks", "Number of webhooks of a repo.", func(c CheckSummary) int { return c.Hooks }},
+		{"webhookit_broken_hooks", "Number of broken webhooks of a repo.", func(c CheckSummary) int { return c.Broken }},
+		{"webhookit_never_triggered_hooks", "Number of never triggered webhooks of a repo.", func(c CheckSummary) int { return c.NeverTriggered }},
+		{"webhookit_duplicate_hooks", "Number of duplicate webhooks of a repo.", func(c CheckSummary) int { return c.Duplicates }},
+		{"webhookit_no_secret_hooks", "Number of webhooks of a repo with no secret configured.", func(c CheckSummary) int { return c.NoSecret }},
+		{"webhookit_repo_scan_failed", "Whether the webhooks of a repo could not be retrieved.", func(c CheckSummary) int { return c.FailedRepos }},
+	}
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, gauge := range gauges {
+		fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s gauge\n", gauge.name, gauge.help, gauge.name)
+		for _, name := range repoNames {
+			fmt.Fprintf(writer, "%s{repo=\"%s\"} %d\n", gauge.name, prometheusLabelEscaper.Replace(name), gauge.value(s.Repos[name]))
+		}
+	}
+	if !s.LastScan.IsZero() {
+		fmt.Fprintf(writer, "# HELP webhookit_last_scan_timestamp_seconds Time the latest scan completed.\n# TYPE webhookit_last_scan_timestamp_seconds gauge\nwebhookit_last_scan_timestamp_seconds %d\n", s.LastScan.Unix())
+		fmt.Fprintf(writer, "# HELP webhookit_scan_duration_seconds Time the latest scan took.\n# TYPE webhookit_scan_duration_seconds gauge\nwebhookit_scan_duration_seconds %f\n", s.Duration.Seconds())
+	}
+}
+
+// writeHealth reports whether a scan has completed
+// @arg writer http.ResponseWriter
+func (s *ServeState) writeHealth(writer http.ResponseWriter) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.LastScan.IsZero() {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(writer, "waiting for first scan")
+		return
+	}
+	fmt.Fprintf(writer, "ok: last scan %s, %d broken webhook(s)\n", s.LastScan.Format(time.RFC3339), s.Summary.Broken)
+}
+
+// scan checks every repo and records the results
+// @arg ctx context.Context - Cancels requests when done
+// @arg state *ServeState
+// @arg filter HookFilter
+func scan(ctx context.Context, state *ServeState, filter HookFilter) {
+	start := time.Now()
+	repos := make(map[string]CheckSummary)
+	// Failed repos are recorded in the results so the error is not needed
+	executeCheck(ctx, CheckOptions{
+		Output:        "json",
+		ResultsOutput: ioutil.Discard,
+		RepoSummaries: repos,
+	}, filter)
+	if ctx.Err() != nil {
+		return
+	}
+	state.update(repos, time.Since(start))
+
+	state.mutex.RLock()
+	summary := state.Summary
+	state.mutex.RUnlock()
+	fmt.Fprintf(infoOutput, "%s %s %d %s %d %s %d %s\n", au.Gray(time.Now().Format(time.RFC3339)), au.Green("Scan complete."), au.Bold(summary.Repos), au.Gray("repos,"), au.Bold(summary.Hooks), au.Gray("hooks,"), au.Bold(au.Red(summary.Broken)), au.Gray("broken"))
+}
+
+// Executes a daemon checking every repo on an interval and serving the results
+// of the latest scan over HTTP at /metrics and /healthz until SIGINT or SIGTERM
+// @arg ctx context.Context - Cancels requests when done
+// @arg addr string - Address to listen on e.g. :8080
+// @arg interval time.Duration - Time between scans
+// @arg filter HookFilter
+// @return error
+func executeServe(ctx context.Context, addr string, interval time.Duration, filter HookFilter) error {
+	// Print title
+	title := fmt.Sprintf("%s\n%s\n%s\n", au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")), au.Bold(au.Brown("              S E R V E")), au.Bold(au.Gray("* * * * * * * * * * * * * * * * * * * *")))
+	fmt.Println(title)
+
+	state := &ServeState{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(writer http.ResponseWriter, request *http.Request) {
+		state.writeMetrics(writer)
+	})
+	mux.HandleFunc("/healthz", func(writer http.ResponseWriter, request *http.Request) {
+		state.writeHealth(writer)
+	})
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	serveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Stop scanning and serving on a signal
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintln(infoOutput, au.Brown(fmt.Sprintf("Received %s, shutting down", sig)))
+		case <-serveCtx.Done():
+		}
+		cancel()
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	// Scan on the interval for as long as the server runs
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			scan(serveCtx, state, filter)
+			select {
+			case <-ticker.C:
+			case <-serveCtx.Done():
+				return
+			}
+		}
+	}()
+
+	fmt.Printf("%s %s %s %s\n\n", au.Bold(au.Gray("Serving /metrics and /healthz on")), au.Bold(au.Brown(addr)), au.Bold(au.Gray("scanning every")), au.Bold(au.Brown(interval)))
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		printError("Issue serving:", err)
+	}
+	// A signal stops serving cleanly but the deadline is an error
+	return ctx.Err()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultSyslogTag is the tag messages are logged to syslog with
+const defaultSyslogTag = "webhookit"
+
+// systemLogger writes messages to a system log at a severity
+type systemLogger interface {
+	Info(message string) error
+	Warning(message string) error
+	Err(message string) error
+}
+
+// syslogLogger receives informational and error output and check results when
+// -syslog is given. Nil disables it.
+var syslogLogger systemLogger
+
+// syslogWriter logs each line written to it at info severity, without colours
+type syslogWriter struct {
+	logger systemLogger
+}
+
+func (w syslogWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(stripANSI(string(p)), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if err := w.logger.Info(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// logLine formats a check result as a single line of key=value pairs
+// @return string
+func (result CheckResult) logLine() string {
+	return fmt.Sprintf("repo=%s hook_id=%d config_url=%q code=%d message=%q active=%t duplicate=%t secret=%t",
+		result.Repo, result.HookID, result.ConfigURL, result.Code, result.Message, result.Active, result.Duplicate, result.Secret)
+}
+
+// logCheckResult logs a check result to syslog, at warning severity if the
+// webhook has a problem. Does nothing without -syslog.
+// @arg result CheckResult
+// @arg problem bool - Whether the webhook is broken or never triggered
+func logCheckResult(result CheckResult, problem bool) {
+	if syslogLogger == nil {
+		return
+	}
+	if problem {
+		syslogLogger.Warning(result.logLine())
+	} else {
+		syslogLogger.Info(result.logLine())
+	}
+}
@@ -0,0 +1,33 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// stderrLogger writes tagged messages to stderr where syslog is not available
+type stderrLogger struct {
+	tag string
+}
+
+func (l stderrLogger) log(severity, message string) error {
+	_, err := fmt.Fprintf(os.Stderr, "%s[%s]: %s\n", l.tag, severity, message)
+	return err
+}
+
+func (l stderrLogger) Info(message string) error    { return l.log("info", message) }
+func (l stderrLogger) Warning(message string) error { return l.log("warning", message) }
+func (l stderrLogger) Err(message string) error     { return l.log("err", message) }
+
+// openSyslog falls back to stderr as syslog is not available on this platform
+// @arg facility string - Ignored
+// @arg tag string
+// @return systemLogger
+// @return error
+func openSyslog(facility, tag string) (systemLogger, error) {
+	fmt.Fprintln(os.Stderr, "syslog is not supported on this platform, logging to stderr instead")
+	return stderrLogger{tag: tag}, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// hookTemplate renders each hook in text output instead of HookWrapper.ToString.
+// Nil uses the default output.
+var hookTemplate *template.Template
+
+// HookTemplateData is the context each hook is rendered with by hookTemplate
+type HookTemplateData struct {
+	// Repo is the name of the repo owning the hook
+	Repo string
+	// ToBeDestroyed is set when the hook will be destroyed or deactivated
+	ToBeDestroyed bool
+	*HookWrapper
+}
+
+// parseHookTemplate parses a Go text/template given inline or, when prefixed
+// with @, read from a file. The template is rendered once with an empty hook so
+// references to missing fields fail before any repo is scanned.
+// @arg value string - Template e.g. '{{.Repo}} {{.Hook.Config.URL}}' or @path/to/file
+// @return *template.Template
+// @return error
+func parseHookTemplate(value string) (*template.Template, error) {
+	text := value
+	if strings.HasPrefix(value, "@") {
+		content, err := ioutil.ReadFile(strings.TrimPrefix(value, "@"))
+		if err != nil {
+			return nil, err
+		}
+		text = strings.TrimSuffix(string(content), "\n")
+	}
+
+	tmpl, err := template.New("hook").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(ioutil.Discard, HookTemplateData{HookWrapper: &HookWrapper{}}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderHook returns the text output of a hook, using hookTemplate when one is given
+// @arg repo string - Name of the repo owning the hook
+// @arg hook *HookWrapper
+// @return string
+func renderHook(repo string, hook *HookWrapper) string {
+	if hookTemplate == nil {
+		return hook.ToString()
+	}
+	var output strings.Builder
+	if err := hookTemplate.Execute(&output, HookTemplateData{Repo: repo, ToBeDestroyed: hook.canDestroy(), HookWrapper: hook}); err != nil {
+		return fmt.Sprint(au.Red(fmt.Sprintf("Error rendering template for %s: %s", hook.Hook.URL, err)))
+	}
+	return output.String()
+}